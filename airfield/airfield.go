@@ -21,6 +21,7 @@
 package airfield
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -65,3 +66,23 @@ const (
 	Gravel          = 1 << iota
 	Dirt            = 1 << iota
 )
+
+// RunwayName returns the value of Airfield.Runway (like 1129) for the given
+// runway designators, from 1 to 36. A second designator of 0 is taken as the
+// reciprocal of the first.
+func RunwayName(first, second int) string {
+	if second == 0 {
+		second = (first+18-1)%36 + 1
+	}
+	return fmt.Sprintf("%02d%02d", first, second)
+}
+
+// RunwayForHeading returns the value of Airfield.Runway for a runway with
+// the given heading (deg).
+func RunwayForHeading(heading int) string {
+	first := (heading + 5) / 10 % 36
+	if first == 0 {
+		first = 36
+	}
+	return RunwayName(first, 0)
+}
@@ -0,0 +1,45 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package airfield
+
+import "testing"
+
+func TestRunwayName(t *testing.T) {
+	tests := []struct {
+		first, second int
+		r             string
+	}{
+		{10, 28, "1028"}, {4, 22, "0422"}, {28, 0, "2810"}, {36, 0, "3618"}, {18, 0, "1836"},
+	}
+	for _, test := range tests {
+		if r := RunwayName(test.first, test.second); r != test.r {
+			t.Errorf("expected %v for %v/%v got %v", test.r, test.first, test.second, r)
+		}
+	}
+}
+
+func TestRunwayForHeading(t *testing.T) {
+	tests := map[int]string{280: "2810", 224: "2204", 0: "3618", 5: "0119", 184: "1836"}
+	for heading, e := range tests {
+		if r := RunwayForHeading(heading); r != e {
+			t.Errorf("expected %v for %v got %v", e, heading, r)
+		}
+	}
+}
@@ -40,35 +40,51 @@ type Airspacer interface {
 //
 // Label is a list of Lat/Lon coordinates where the airspace label
 // (usually the name) should be placed.
+//
+// Floor and Ceiling are the vertical limits as given by the source, parsed
+// by FloorLimit and CeilingLimit.
+//
+// Type, Frequency (MHz) and Station are the airspace type (like TMZ or
+// GLIDING), and the frequency and name of the controlling station.
+//
+// Activation holds the activation periods, as given by the source.
+//
+// Extra holds source lines with no matching field, kept as given.
 type Airspace struct {
-	ID       string
-	Date     time.Time
-	Class    byte
-	Name     string
-	Ceiling  string
-	Floor    string
-	Label    []string
-	Segments []Segment
-	Pen      Pen
-	Update   time.Time
+	ID         string
+	Date       time.Time
+	Class      byte
+	Type       string
+	Name       string
+	Ceiling    string
+	Floor      string
+	Frequency  float64
+	Station    string
+	Activation []string
+	Label      []string
+	Segments   []Segment
+	Pen        Pen
+	Extra      []string
+	Update     time.Time
 }
 
-// Segment is one of polygon, arc, circle.
+// Segment is one of polygon, arc, circle, airway.
 //
 // Clockwise indicates direction for building arcs.
 //
-// X is the center for arcs and circles, W is the width for airways (unused).
+// X is the center for arcs and circles, W is the width (nm) for airways.
 //
 // Data interpretation depends on record type:
 //   Polygon: coordinate point (to be added)
 //   Arc: radius, start, end || coordinate1, coordinate2 (center in X)
 //   Circle: radius (from X)
+//   Airway: coordinate point of the airway center line (to be added)
 //
 type Segment struct {
 	Type        SegmentType
 	Clockwise   bool
 	X           string
-	W           int
+	W           float64
 	Radius      float64
 	AngleStart  float64
 	AngleEnd    float64
@@ -84,6 +100,7 @@ const (
 	Polygon SegmentType = iota
 	Arc
 	Circle
+	Airway
 )
 
 // Pen has drawing info for an Airspace.
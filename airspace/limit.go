@@ -0,0 +1,198 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package airspace
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rochaporto/ezgliding/util"
+)
+
+// Unit is the unit of a Limit value.
+type Unit int
+
+const (
+	// Feet unit.
+	Feet Unit = iota
+	// Meters unit.
+	Meters
+	// FlightLevel unit, in hundreds of feet.
+	FlightLevel
+)
+
+func (u Unit) String() string {
+	switch u {
+	case Feet:
+		return "ft"
+	case Meters:
+		return "m"
+	case FlightLevel:
+		return "FL"
+	default:
+		return "unknown"
+	}
+}
+
+// Reference is the reference of a Limit value.
+type Reference int
+
+const (
+	// MSL is above the mean sea level.
+	MSL Reference = iota
+	// AGL is above the ground level.
+	AGL
+	// SFC is the surface, or above it when given a value.
+	SFC
+	// GND is the ground, same as SFC.
+	GND
+	// UNL is unlimited.
+	UNL
+	// STD is the standard pressure (1013.25 hPa), used by flight levels.
+	STD
+)
+
+func (r Reference) String() string {
+	switch r {
+	case MSL:
+		return "MSL"
+	case AGL:
+		return "AGL"
+	case SFC:
+		return "SFC"
+	case GND:
+		return "GND"
+	case UNL:
+		return "UNL"
+	case STD:
+		return "STD"
+	default:
+		return "unknown"
+	}
+}
+
+// Limit is a vertical limit (floor or ceiling) of an airspace.
+type Limit struct {
+	Value     float64
+	Unit      Unit
+	Reference Reference
+}
+
+// StandardPressure is the pressure (hPa) used as reference by flight levels.
+const StandardPressure = 1013.25
+
+var limitRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(FT|F|M)?\s*(AMSL|MSL|AGL|ASFC|SFC|GND)?$`)
+
+// ParseLimit parses the given floor or ceiling, such as FL65, 1500ft AGL,
+// 2000 AMSL, SFC or UNL. Values with no unit are in feet, and above the mean
+// sea level unless given otherwise.
+func ParseLimit(s string) (Limit, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	switch v {
+	case "SFC":
+		return Limit{Reference: SFC}, nil
+	case "GND":
+		return Limit{Reference: GND}, nil
+	case "UNL", "UNLIM", "UNLIMITED":
+		return Limit{Reference: UNL}, nil
+	}
+	if strings.HasPrefix(v, "FL") {
+		fl, err := strconv.ParseFloat(strings.TrimSpace(v[2:]), 64)
+		if err != nil {
+			return Limit{}, fmt.Errorf("failed to parse limit '%v' :: %v", s, err)
+		}
+		return Limit{Value: fl, Unit: FlightLevel, Reference: STD}, nil
+	}
+	m := limitRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return Limit{}, fmt.Errorf("failed to parse limit '%v'", s)
+	}
+	l := Limit{Unit: Feet, Reference: MSL}
+	l.Value, _ = strconv.ParseFloat(m[1], 64)
+	if m[2] == "M" {
+		l.Unit = Meters
+	}
+	switch m[3] {
+	case "AGL", "ASFC":
+		l.Reference = AGL
+	case "SFC":
+		l.Reference = SFC
+	case "GND":
+		l.Reference = GND
+	}
+	return l, nil
+}
+
+// Meters returns the limit in meters above the mean sea level, for the given
+// terrain elevation (m) and QNH (hPa, 0 for the standard pressure).
+//
+// Flight levels are converted using the standard atmosphere, so with the
+// standard pressure the result is the pressure altitude. Unlimited results
+// in positive infinity.
+func (l Limit) Meters(terrain float64, qnh float64) float64 {
+	v := l.Value
+	switch l.Unit {
+	case Feet:
+		v *= util.Foot
+	case FlightLevel:
+		v *= 100 * util.Foot
+	}
+	switch l.Reference {
+	case AGL, SFC, GND:
+		return terrain + v
+	case UNL:
+		return math.Inf(1)
+	case STD:
+		if qnh == 0 {
+			qnh = StandardPressure
+		}
+		// pressure altitude of the qnh level
+		return v - 44330.77*(1-math.Pow(qnh/StandardPressure, 0.190263))
+	}
+	return v
+}
+
+func (l Limit) String() string {
+	value := strconv.FormatFloat(l.Value, 'f', -1, 64)
+	switch {
+	case l.Reference == UNL:
+		return "UNL"
+	case (l.Reference == SFC || l.Reference == GND) && l.Value == 0:
+		return l.Reference.String()
+	case l.Unit == FlightLevel:
+		return "FL" + value
+	case l.Reference == MSL:
+		return value + l.Unit.String() + " AMSL"
+	}
+	return value + l.Unit.String() + " " + l.Reference.String()
+}
+
+// FloorLimit returns the parsed airspace floor.
+func (a Airspace) FloorLimit() (Limit, error) {
+	return ParseLimit(a.Floor)
+}
+
+// CeilingLimit returns the parsed airspace ceiling.
+func (a Airspace) CeilingLimit() (Limit, error) {
+	return ParseLimit(a.Ceiling)
+}
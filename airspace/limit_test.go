@@ -0,0 +1,129 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package airspace
+
+import (
+	"math"
+	"testing"
+)
+
+type limitTest struct {
+	in string
+	l  Limit
+	// expected meters for terrain 500 and standard pressure
+	meters float64
+	s      string
+}
+
+var limitTests = []limitTest{
+	{"FL65", Limit{65, FlightLevel, STD}, 1981.2, "FL65"},
+	{"FL 115", Limit{115, FlightLevel, STD}, 3505.2, "FL115"},
+	{"SFC", Limit{0, Feet, SFC}, 500, "SFC"},
+	{"gnd", Limit{0, Feet, GND}, 500, "GND"},
+	{"UNL", Limit{0, Feet, UNL}, math.Inf(1), "UNL"},
+	{"Unlimited", Limit{0, Feet, UNL}, math.Inf(1), "UNL"},
+	{"1500ft AGL", Limit{1500, Feet, AGL}, 957.2, "1500ft AGL"},
+	{"1500FT AMSL", Limit{1500, Feet, MSL}, 457.2, "1500ft AMSL"},
+	{"2000", Limit{2000, Feet, MSL}, 609.6, "2000ft AMSL"},
+	{"1000M AMSL", Limit{1000, Meters, MSL}, 1000, "1000m AMSL"},
+	{"300 m agl", Limit{300, Meters, AGL}, 800, "300m AGL"},
+	{"3500FT SFC", Limit{3500, Feet, SFC}, 1566.8, "3500ft SFC"},
+	{"1500 ft ASFC", Limit{1500, Feet, AGL}, 957.2, "1500ft AGL"},
+	{" 4500.5F MSL ", Limit{4500.5, Feet, MSL}, 1371.75, "4500.5ft AMSL"},
+}
+
+func TestParseLimit(t *testing.T) {
+	for _, test := range limitTests {
+		l, err := ParseLimit(test.in)
+		if err != nil {
+			t.Errorf("%v :: failed to parse :: %v", test.in, err)
+			continue
+		}
+		if l != test.l {
+			t.Errorf("%v :: expected %+v got %+v", test.in, test.l, l)
+		}
+		m := l.Meters(500, 0)
+		if math.Abs(m-test.meters) > 0.01 && !math.IsInf(test.meters, 1) || math.IsInf(m, 1) != math.IsInf(test.meters, 1) {
+			t.Errorf("%v :: expected %v meters got %v", test.in, test.meters, m)
+		}
+		if l.String() != test.s {
+			t.Errorf("%v :: expected string %v got %v", test.in, test.s, l.String())
+		}
+	}
+}
+
+func TestParseLimitInvalid(t *testing.T) {
+	for _, s := range []string{"", "FL", "FLxx", "high", "1500 feet", "-100 AGL", "1500 ft agl msl"} {
+		if l, err := ParseLimit(s); err == nil {
+			t.Errorf("%v :: expected error got %+v", s, l)
+		}
+	}
+}
+
+func TestLimitMetersQNH(t *testing.T) {
+	tests := []struct {
+		l      Limit
+		qnh    float64
+		meters float64
+	}{
+		{Limit{65, FlightLevel, STD}, 1013.25, 1981.2},
+		{Limit{65, FlightLevel, STD}, 1023.25, 2064.1},
+		{Limit{65, FlightLevel, STD}, 1003.25, 1897.7},
+		// qnh does not change other references
+		{Limit{1500, Feet, MSL}, 1023.25, 457.2},
+		{Limit{1500, Feet, AGL}, 1023.25, 957.2},
+	}
+	for _, test := range tests {
+		if m := test.l.Meters(500, test.qnh); math.Abs(m-test.meters) > 0.5 {
+			t.Errorf("%v at %v :: expected %v got %v", test.l, test.qnh, test.meters, m)
+		}
+	}
+}
+
+func TestAirspaceLimits(t *testing.T) {
+	a := Airspace{Floor: "1500ft AGL", Ceiling: "FL95"}
+	floor, err := a.FloorLimit()
+	if err != nil || floor != (Limit{1500, Feet, AGL}) {
+		t.Errorf("expected floor 1500ft AGL got %v :: %v", floor, err)
+	}
+	ceiling, err := a.CeilingLimit()
+	if err != nil || ceiling != (Limit{95, FlightLevel, STD}) {
+		t.Errorf("expected ceiling FL95 got %v :: %v", ceiling, err)
+	}
+	if _, err := (Airspace{}).FloorLimit(); err == nil {
+		t.Errorf("expected error for empty floor got none")
+	}
+}
+
+func TestUnitReferenceString(t *testing.T) {
+	units := map[Unit]string{Feet: "ft", Meters: "m", FlightLevel: "FL", Unit(10): "unknown"}
+	for k, v := range units {
+		if k.String() != v {
+			t.Errorf("expected %v got %v", v, k.String())
+		}
+	}
+	references := map[Reference]string{MSL: "MSL", AGL: "AGL", SFC: "SFC", GND: "GND", UNL: "UNL",
+		STD: "STD", Reference(10): "unknown"}
+	for k, v := range references {
+		if k.String() != v {
+			t.Errorf("expected %v got %v", v, k.String())
+		}
+	}
+}
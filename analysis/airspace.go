@@ -0,0 +1,163 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// Infringement is a period inside an airspace.
+type Infringement struct {
+	Name  string
+	Class byte
+	// Start and End are the indices of the first and last points inside.
+	Start int
+	End   int
+	Entry time.Time
+	Exit  time.Time
+	// Vertical and Lateral (m) are the max distances inside the airspace,
+	// to the closest of floor or ceiling and to the lateral boundary.
+	Vertical float64
+	Lateral  float64
+}
+
+// Skipped is an airspace left out of the check, with the reason.
+type Skipped struct {
+	Name string
+	Err  error
+}
+
+// Infringements returns the periods, between takeoff and landing, where the
+// flight was inside any of the given airspaces. Airspaces with no lateral
+// limits are ignored, those with an invalid geometry or vertical limit are
+// returned as skipped and the rest are still checked.
+//
+// Flight levels are checked against the pressure altitude, other limits
+// against the GNSS altitude when available. Limits above ground use the
+// takeoff elevation as the terrain elevation.
+func Infringements(f flight.Flight, airspaces []airspace.Airspace) ([]Infringement, []Skipped) {
+	tr := newTrack(f.Points)
+	takeoff, landing := tr.flight()
+	if takeoff < 0 {
+		return nil, nil
+	}
+	terrain := tr.alt[takeoff]
+	var result []Infringement
+	var skipped []Skipped
+	for _, a := range airspaces {
+		polygon, err := spatial.AirspacePolygon(a, spatial.DefaultArcResolution)
+		if err != nil {
+			skipped = append(skipped, Skipped{a.Name, fmt.Errorf("invalid geometry :: %v", err)})
+			continue
+		}
+		if len(polygon) < 4 {
+			continue
+		}
+		floor, err := a.FloorLimit()
+		if err != nil {
+			skipped = append(skipped, Skipped{a.Name, fmt.Errorf("invalid floor :: %v", err)})
+			continue
+		}
+		ceiling, err := a.CeilingLimit()
+		if err != nil {
+			skipped = append(skipped, Skipped{a.Name, fmt.Errorf("invalid ceiling :: %v", err)})
+			continue
+		}
+		lower, upper := limit{floor, terrain}, limit{ceiling, terrain}
+		var current *Infringement
+		for i := takeoff; i <= landing; i++ {
+			p := tr.points[i]
+			vertical := math.Min(lower.below(p), upper.above(p))
+			inside := vertical >= 0 && spatial.InPolygon(p.Latitude, p.Longitude, polygon)
+			if !inside {
+				current = nil
+				continue
+			}
+			if current == nil {
+				result = append(result, Infringement{Name: a.Name, Class: a.Class, Start: i, Entry: p.Time})
+				current = &result[len(result)-1]
+			}
+			current.End, current.Exit = i, p.Time
+			current.Vertical = math.Max(current.Vertical, vertical)
+			current.Lateral = math.Max(current.Lateral, edgeDistance(p.Latitude, p.Longitude, polygon))
+		}
+	}
+	return result, skipped
+}
+
+// limit is an airspace vertical limit, for the given terrain elevation (m).
+type limit struct {
+	airspace.Limit
+	terrain float64
+}
+
+// altitude returns the altitude (m) of p to compare with the limit: the
+// pressure altitude for flight levels and the GNSS altitude otherwise, when
+// available.
+func (l limit) altitude(p flight.Point) float64 {
+	pressure, gnss := float64(p.PressureAltitude), float64(p.GNSSAltitude)
+	if l.Reference == airspace.STD && pressure != 0 || gnss == 0 {
+		return pressure
+	}
+	return gnss
+}
+
+// value returns the limit altitude (m), with flight levels as pressure
+// altitudes.
+func (l limit) value() float64 {
+	return l.Meters(l.terrain, airspace.StandardPressure)
+}
+
+// below returns how far (m) p is above the limit, negative if below.
+func (l limit) below(p flight.Point) float64 {
+	return l.altitude(p) - l.value()
+}
+
+// above returns how far (m) p is below the limit, negative if above.
+func (l limit) above(p flight.Point) float64 {
+	return l.value() - l.altitude(p)
+}
+
+// edgeDistance returns the distance (m) of the given position to the closest
+// polygon edge, in a local flat projection.
+func edgeDistance(lat, lon float64, polygon []spatial.Coordinate) float64 {
+	scale := math.Cos(spatial.Radians(lat))
+	project := func(c spatial.Coordinate) (float64, float64) {
+		return spatial.Radians(c.Longitude-lon) * scale * spatial.EarthRadius, spatial.Radians(c.Latitude-lat) * spatial.EarthRadius
+	}
+	result := math.Inf(1)
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		x1, y1 := project(polygon[j])
+		x2, y2 := project(polygon[i])
+		dx, dy := x2-x1, y2-y1
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/l))
+		}
+		result = math.Min(result, math.Hypot(x1+t*dx, y1+t*dy))
+	}
+	return result
+}
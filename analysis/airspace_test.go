@@ -0,0 +1,162 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/openair"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// coordinate returns the OpenAir coordinate at the given bearing (deg) and
+// distance (km) from the builder origin.
+func coordinate(bearing, km float64) string {
+	b := newBuilder()
+	lat, lon := spatial.Destination(b.lat, b.lon, bearing, km*1000)
+	return fmt.Sprintf("%d:%06.3f N %03d:%06.3f E", int(lat), (lat-math.Floor(lat))*60,
+		int(lon), (lon-math.Floor(lon))*60)
+}
+
+// square returns the polygon segments of a square centered north of the
+// builder origin, at the given distance (km) and with the given side (km).
+func square(km, side float64) []airspace.Segment {
+	var result []airspace.Segment
+	for _, c := range [][2]float64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		n, e := km+c[0]*side/2, c[1]*side/2
+		result = append(result, airspace.Segment{Type: airspace.Polygon,
+			Coordinate1: coordinate(math.Atan2(e, n)*180/math.Pi, math.Hypot(n, e))})
+	}
+	return result
+}
+
+// halfCircle returns the airspace parsed from OpenAir with a DB arc in the
+// default (clockwise) direction, closed by a line through its center. The
+// arc goes south of the center, at the given distance (km) north of the
+// builder origin, with the given radius (km).
+func halfCircle(km, radius float64) airspace.Airspace {
+	east, west := coordinate(math.Atan2(radius, km)*180/math.Pi, math.Hypot(km, radius)),
+		coordinate(-math.Atan2(radius, km)*180/math.Pi, math.Hypot(km, radius))
+	content := fmt.Sprintf("AC D\nAN CTR DB\nAH FL65\nAL SFC\nV X=%v\nDB %v,%v\n", coordinate(0, km), east, west)
+	a, err := openair.Parse([]byte(content))
+	if err != nil || len(a) != 1 {
+		return airspace.Airspace{}
+	}
+	return a[0]
+}
+
+type infringementTest struct {
+	t        string
+	a        airspace.Airspace
+	start    int
+	end      int
+	vertical float64
+	lateral  float64
+}
+
+// the flight climbs north to 9 km and 1350 m, and glides north for 18 km
+var infringementTests = []infringementTest{
+	{
+		t: "circle from surface to fl65",
+		a: airspace.Airspace{Name: "CTR", Class: 'D', Floor: "SFC", Ceiling: "FL65",
+			Segments: []airspace.Segment{{Type: airspace.Circle, X: coordinate(0, 13.5), Radius: 1}}},
+		start: 448, end: 572, vertical: 765, lateral: 1852,
+	},
+	{
+		t: "circle above the flight",
+		a: airspace.Airspace{Name: "TMA", Class: 'C', Floor: "5000ft AMSL", Ceiling: "FL115",
+			Segments: []airspace.Segment{{Type: airspace.Circle, X: coordinate(0, 13.5), Radius: 1}}},
+		start: -1,
+	},
+	{
+		t: "polygon up to 3000ft agl",
+		a: airspace.Airspace{Name: "R 1", Class: 'R', Floor: "GND", Ceiling: "3000ft AGL",
+			Segments: square(4.5, 3)},
+		start: 160, end: 260, vertical: 457, lateral: 1500,
+	},
+	{
+		t:     "db arc in the default direction",
+		a:     halfCircle(13.5, 2),
+		start: 443, end: 510, vertical: 765, lateral: 1000,
+	},
+	{
+		t: "degenerate polygon",
+		a: airspace.Airspace{Name: "P 2", Class: 'P', Floor: "SFC", Ceiling: "UNL",
+			Segments: square(4.5, 3)[:1]},
+		start: -1,
+	},
+}
+
+func TestInfringements(t *testing.T) {
+	f := newBuilder().ground(60).straight(300, 30, 3).straight(600, 30, -1).ground(60).flight()
+	for _, test := range infringementTests {
+		result, skipped := Infringements(f, []airspace.Airspace{test.a})
+		if len(skipped) != 0 {
+			t.Errorf("%v :: failed to check infringements :: %+v", test.t, skipped)
+			continue
+		}
+		if test.start < 0 {
+			if len(result) != 0 {
+				t.Errorf("%v :: expected no infringements got %+v", test.t, result)
+			}
+			continue
+		}
+		if len(result) != 1 {
+			t.Errorf("%v :: expected one infringement got %+v", test.t, result)
+			continue
+		}
+		r := result[0]
+		if r.Name != test.a.Name || r.Class != test.a.Class || !near(r.Start, test.start) ||
+			!near(r.End, test.end) || !r.Entry.Equal(f.Points[r.Start].Time) ||
+			!r.Exit.Equal(f.Points[r.End].Time) {
+			t.Errorf("%v :: expected infringement %v to %v got %+v", test.t, test.start, test.end, r)
+		}
+		if math.Abs(r.Vertical-test.vertical) > 30 || math.Abs(r.Lateral-test.lateral) > 50 {
+			t.Errorf("%v :: expected margins %v %v got %v %v", test.t,
+				test.vertical, test.lateral, r.Vertical, r.Lateral)
+		}
+	}
+}
+
+func TestInfringementsInvalid(t *testing.T) {
+	f := newBuilder().ground(60).straight(300, 30, 3).straight(600, 30, -1).ground(60).flight()
+	tests := []airspace.Airspace{
+		{Name: "floor", Floor: "high", Ceiling: "FL65", Segments: square(4.5, 3)},
+		{Name: "ceiling", Floor: "SFC", Ceiling: "FLxx", Segments: square(4.5, 3)},
+		{Name: "coordinate", Floor: "SFC", Ceiling: "FL65",
+			Segments: []airspace.Segment{{Type: airspace.Circle, X: "46 N", Radius: 1}}},
+	}
+	valid := infringementTests[0].a
+	result, skipped := Infringements(f, append(tests, valid))
+	if len(result) != 1 || result[0].Name != valid.Name {
+		t.Errorf("expected one infringement of %v got %+v", valid.Name, result)
+	}
+	if len(skipped) != len(tests) {
+		t.Fatalf("expected %v skipped got %+v", len(tests), skipped)
+	}
+	for i, test := range tests {
+		if skipped[i].Name != test.Name || skipped[i].Err == nil {
+			t.Errorf("%v :: expected skipped with error got %+v", test.Name, skipped[i])
+		}
+	}
+}
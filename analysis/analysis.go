@@ -0,0 +1,335 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package analysis provides analysis of flight tracks, as parsed into
+// flight.Flight. This includes takeoff and landing detection and the split
+// of the flight in phases (launch, circling, cruising and final glide), with
+// thermal detection and statistics, wind estimation and engine run detection.
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+const (
+	// flyingSpeed is the ground speed (m/s) above which the glider is flying.
+	flyingSpeed = 10.0
+	// minFlightDuration is the min duration (s) of a flight.
+	minFlightDuration = 60.0
+	// minFlightHeight is the min altitude gain (m) after takeoff for a flight.
+	minFlightHeight = 50.0
+	// minGroundDuration is the min duration (s) of a stop ending a flight.
+	minGroundDuration = 60.0
+	// speedWindow is the time window (s) used to smooth the ground speed.
+	speedWindow = 10.0
+	// turnWindow is the time window (s) used to compute the turn rate.
+	turnWindow = 10.0
+	// circlingRate is the min turn rate (deg/s) when circling.
+	circlingRate = 4.0
+	// minCirclingDuration is the min duration (s) of a circling phase.
+	minCirclingDuration = 30.0
+	// maxCirclingGap is the max duration (s) of a straight section inside
+	// a circling phase, as when centering a thermal.
+	maxCirclingGap = 15.0
+	// releaseDrop is the altitude loss (m) after the top of the launch
+	// marking the release.
+	releaseDrop = 30.0
+)
+
+// PhaseType is the type of a flight phase.
+type PhaseType int
+
+const (
+	// Launch is the tow or winch launch, up to the release.
+	Launch PhaseType = iota
+	// Circling is a phase spent turning, usually in a thermal.
+	Circling
+	// Cruising is a phase spent flying straight between thermals.
+	Cruising
+	// FinalGlide is the last cruising phase, ending with the landing.
+	FinalGlide
+)
+
+func (t PhaseType) String() string {
+	switch t {
+	case Launch:
+		return "launch"
+	case Circling:
+		return "circling"
+	case Cruising:
+		return "cruising"
+	case FinalGlide:
+		return "final glide"
+	default:
+		return "unknown"
+	}
+}
+
+// Phase is a section of the flight, from the Start to the End (inclusive)
+// indices in the flight points.
+type Phase struct {
+	Type  PhaseType
+	Start int
+	End   int
+}
+
+// Analysis holds the result of the analysis of a flight.
+type Analysis struct {
+	// Takeoff and Landing are the indices of the takeoff and landing
+	// points in the flight points, -1 if no flight was found.
+	Takeoff int
+	Landing int
+	// Phases covers the flight from takeoff to landing, in order.
+	Phases []Phase
+	// Thermals holds the details of the circling phases.
+	Thermals     []Thermal
+	ThermalStats ThermalStats
+	// Winds holds the wind estimates, one per full circle, in time order.
+	Winds []Wind
+	// EngineRuns holds the periods with the engine running. Without ENL or
+	// MOP data they are guessed from the straight climbs.
+	EngineRuns []EngineRun
+}
+
+// Analyze returns the analysis of the given flight.
+//
+// Takeoff and landing are detected from the ground speed and altitude
+// change, so points recorded before and after the flight (logger left
+// running on the ground) are ignored. When the logger has more than one
+// flight, only the first is analyzed.
+func Analyze(f flight.Flight) Analysis {
+	tr := newTrack(f.Points)
+	a := Analysis{Takeoff: -1, Landing: -1}
+	a.Takeoff, a.Landing = tr.flight()
+	if a.Takeoff < 0 {
+		a.EngineRuns = tr.engineRuns(nil)
+		return a
+	}
+	a.Phases = tr.phases(a.Takeoff, a.Landing)
+	a.Thermals = tr.thermals(a.Phases)
+	a.ThermalStats = tr.thermalStats(a)
+	a.Winds = tr.winds(a.Thermals)
+	a.EngineRuns = tr.engineRuns(a.Phases)
+	return a
+}
+
+// track holds values derived from the flight points, by point index.
+type track struct {
+	points []flight.Point
+	// t is the time (s) since the first point.
+	t []float64
+	// alt is the altitude (m), pressure if available or GNSS otherwise.
+	alt []float64
+	// dist is the distance (m) flown since the first point.
+	dist []float64
+	// heading is the track direction (deg), unwrapped so that the change
+	// between points gives the amount turned (positive is clockwise).
+	heading []float64
+}
+
+func newTrack(points []flight.Point) *track {
+	n := len(points)
+	tr := track{points: points, t: make([]float64, n), alt: make([]float64, n),
+		dist: make([]float64, n), heading: make([]float64, n)}
+	pressure := false
+	for _, p := range points {
+		if p.PressureAltitude != 0 {
+			pressure = true
+			break
+		}
+	}
+	for i, p := range points {
+		tr.alt[i] = float64(p.GNSSAltitude)
+		if pressure {
+			tr.alt[i] = float64(p.PressureAltitude)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := points[i-1]
+		tr.t[i] = p.Time.Sub(points[0].Time).Seconds()
+		d := spatial.Distance(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		tr.dist[i] = tr.dist[i-1] + d
+		tr.heading[i] = tr.heading[i-1]
+		if d > 1 {
+			b := spatial.Bearing(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+			if i == 1 {
+				tr.heading[i] = b
+			} else {
+				tr.heading[i] += math.Remainder(b-tr.heading[i-1], 360)
+			}
+		}
+	}
+	if n > 1 {
+		tr.heading[0] = tr.heading[1]
+	}
+	return &tr
+}
+
+// window returns the indices of the first and last points in the time
+// window of size w centered at point i.
+func (tr *track) window(i int, w float64) (int, int) {
+	start := sort.SearchFloat64s(tr.t, tr.t[i]-w/2)
+	end := sort.SearchFloat64s(tr.t, tr.t[i]+w/2+1e-9) - 1
+	if end < i {
+		end = i
+	}
+	return start, end
+}
+
+// rate returns the change per second of the given values around point i,
+// using a time window of size w.
+func (tr *track) rate(values []float64, i int, w float64) float64 {
+	start, end := tr.window(i, w)
+	dt := tr.t[end] - tr.t[start]
+	if dt <= 0 {
+		return 0
+	}
+	return (values[end] - values[start]) / dt
+}
+
+// speed returns the ground speed (m/s) at point i.
+func (tr *track) speed(i int) float64 {
+	return tr.rate(tr.dist, i, speedWindow)
+}
+
+// turnRate returns the turn rate (deg/s) at point i, positive is clockwise.
+func (tr *track) turnRate(i int) float64 {
+	return tr.rate(tr.heading, i, turnWindow)
+}
+
+// duration returns the time (s) between points i and j.
+func (tr *track) duration(i, j int) float64 {
+	return tr.t[j] - tr.t[i]
+}
+
+// flight returns the indices of the takeoff and landing points of the first
+// flight found, or -1 if there is none.
+//
+// A flight is a section where the glider is moving, with no stop longer than
+// minGroundDuration, lasting at least minFlightDuration and gaining at least
+// minFlightHeight over the takeoff altitude.
+func (tr *track) flight() (int, int) {
+	n := len(tr.points)
+	i := 0
+	for i < n {
+		for i < n && tr.speed(i) <= flyingSpeed {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		start, end := i, i
+		for i < n {
+			if tr.speed(i) > flyingSpeed {
+				end = i
+			} else if tr.duration(end, i) >= minGroundDuration {
+				break
+			}
+			i++
+		}
+		top := tr.alt[start]
+		for j := start; j <= end; j++ {
+			top = math.Max(top, tr.alt[j])
+		}
+		if tr.duration(start, end) >= minFlightDuration && top-tr.alt[start] >= minFlightHeight {
+			return start, end
+		}
+	}
+	return -1, -1
+}
+
+// phases splits the flight between the given takeoff and landing points.
+func (tr *track) phases(takeoff, landing int) []Phase {
+	circling := tr.circling(takeoff, landing)
+	release := tr.release(takeoff, landing)
+	if len(circling) > 0 && circling[0].Start <= release {
+		release = circling[0].Start - 1
+		if release <= takeoff {
+			release = takeoff + 1
+		}
+	}
+	result := []Phase{{Type: Launch, Start: takeoff, End: release}}
+	next := release + 1
+	for _, c := range circling {
+		if c.End <= release {
+			continue
+		}
+		if c.Start < next {
+			c.Start = next
+		}
+		if c.Start > next {
+			result = append(result, Phase{Type: Cruising, Start: next, End: c.Start - 1})
+		}
+		result = append(result, c)
+		next = c.End + 1
+	}
+	if next <= landing {
+		result = append(result, Phase{Type: FinalGlide, Start: next, End: landing})
+	}
+	return result
+}
+
+// release returns the index of the release point, the top of the launch
+// before the first altitude loss of releaseDrop.
+func (tr *track) release(takeoff, landing int) int {
+	top := takeoff
+	for i := takeoff; i <= landing; i++ {
+		if tr.alt[i] > tr.alt[top] {
+			top = i
+		} else if tr.alt[top]-tr.alt[i] >= releaseDrop {
+			break
+		}
+	}
+	if top == takeoff && takeoff < landing {
+		top++
+	}
+	return top
+}
+
+// circling returns the circling phases between the given points.
+//
+// Circling is detected where the turn rate is above circlingRate. Straight
+// sections shorter than maxCirclingGap are merged into the circling phase,
+// which then must last at least minCirclingDuration and turn a full circle.
+func (tr *track) circling(from, to int) []Phase {
+	var raw []Phase
+	for i := from; i <= to; i++ {
+		if math.Abs(tr.turnRate(i)) < circlingRate {
+			continue
+		}
+		if len(raw) > 0 && tr.duration(raw[len(raw)-1].End, i) <= maxCirclingGap {
+			raw[len(raw)-1].End = i
+		} else {
+			raw = append(raw, Phase{Type: Circling, Start: i, End: i})
+		}
+	}
+	var result []Phase
+	for _, c := range raw {
+		if tr.duration(c.Start, c.End) >= minCirclingDuration &&
+			math.Abs(tr.heading[c.End]-tr.heading[c.Start]) >= 360 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,257 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// builder generates synthetic tracks, with one point per second.
+type builder struct {
+	points   []flight.Point
+	t        time.Time
+	lat, lon float64
+	alt      float64
+	heading  float64
+	// windSpeed (m/s) and windDir (deg, direction the wind comes from)
+	// are added to the glider movement.
+	windSpeed float64
+	windDir   float64
+	// extensions are added to the IData of each point.
+	extensions map[string]string
+}
+
+func newBuilder() *builder {
+	return &builder{t: time.Date(2015, 6, 20, 9, 0, 0, 0, time.UTC), lat: 46.2, lon: 6.1, alt: 450}
+}
+
+func (b *builder) add() {
+	p := flight.NewPoint()
+	p.Time, p.Latitude, p.Longitude = b.t, b.lat, b.lon
+	p.FixValidity = 'A'
+	p.PressureAltitude = int64(math.Floor(b.alt + 0.5))
+	p.GNSSAltitude = p.PressureAltitude
+	for k, v := range b.extensions {
+		p.IData[k] = v
+	}
+	b.points = append(b.points, p)
+	b.t = b.t.Add(time.Second)
+}
+
+// move moves for one second, at the given airspeed and climb (m/s).
+func (b *builder) move(speed float64, climb float64) {
+	b.lat, b.lon = spatial.Destination(b.lat, b.lon, b.heading, speed)
+	if b.windSpeed > 0 {
+		b.lat, b.lon = spatial.Destination(b.lat, b.lon, math.Mod(b.windDir+180, 360), b.windSpeed)
+	}
+	b.alt += climb
+	b.add()
+}
+
+// ground adds points without moving.
+func (b *builder) ground(seconds int) *builder {
+	for i := 0; i < seconds; i++ {
+		b.add()
+	}
+	return b
+}
+
+// straight flies in the current heading.
+func (b *builder) straight(seconds int, speed float64, climb float64) *builder {
+	for i := 0; i < seconds; i++ {
+		b.move(speed, climb)
+	}
+	return b
+}
+
+// circle turns at the given rate (deg/s, positive is clockwise).
+func (b *builder) circle(seconds int, speed float64, climb float64, rate float64) *builder {
+	for i := 0; i < seconds; i++ {
+		b.heading = math.Mod(b.heading+rate+360, 360)
+		b.move(speed, climb)
+	}
+	return b
+}
+
+// extension sets the value of the given extension in the next points.
+func (b *builder) extension(code string, value string) *builder {
+	if b.extensions == nil {
+		b.extensions = make(map[string]string)
+	}
+	b.extensions[code] = value
+	return b
+}
+
+// turn sets the heading.
+func (b *builder) turn(heading float64) *builder {
+	b.heading = heading
+	return b
+}
+
+func (b *builder) flight() flight.Flight {
+	f := flight.NewFlight()
+	f.Points = b.points
+	return f
+}
+
+// testFlight returns a flight with 10 min on the ground, a 5 min tow, two
+// thermals (left and right turns) and a final glide, followed by another
+// 10 min on the ground.
+func testFlight() flight.Flight {
+	return newBuilder().ground(600).
+		turn(45).straight(5, 10, 0).straight(300, 30, 2.5).
+		straight(60, 25, -1).circle(300, 22, 2, -12).
+		turn(90).straight(600, 30, -1).circle(180, 22, 1.5, 10).
+		turn(200).straight(600, 30, -1.5).straight(10, 10, 0).
+		ground(600).flight()
+}
+
+type phaseTest struct {
+	t     string
+	f     flight.Flight
+	types []PhaseType
+	// starts holds the expected start index of each phase, and the
+	// landing index as the last element.
+	starts []int
+}
+
+var phaseTests = []phaseTest{
+	{
+		t:      "full flight",
+		f:      testFlight(),
+		types:  []PhaseType{Launch, Cruising, Circling, Cruising, Circling, FinalGlide},
+		starts: []int{600, 906, 966, 1266, 1866, 2046, 2656},
+	},
+	{
+		t: "winch launch and circuit",
+		f: newBuilder().ground(300).straight(40, 25, 10).turn(180).
+			straight(300, 25, -1.3).ground(60).flight(),
+		types:  []PhaseType{Launch, FinalGlide},
+		starts: []int{300, 340, 640},
+	},
+	{
+		t: "ends circling",
+		f: newBuilder().straight(300, 30, 2.5).straight(60, 25, -1).
+			circle(120, 22, 2, 12).ground(60).flight(),
+		types:  []PhaseType{Launch, Cruising, Circling},
+		starts: []int{0, 300, 360, 480},
+	},
+}
+
+func TestAnalyzePhases(t *testing.T) {
+	for _, test := range phaseTests {
+		a := Analyze(test.f)
+		if len(a.Phases) != len(test.types) {
+			t.Errorf("%v :: expected %v phases got %+v", test.t, len(test.types), a.Phases)
+			continue
+		}
+		if !near(a.Takeoff, test.starts[0]) || !near(a.Landing, test.starts[len(test.starts)-1]) {
+			t.Errorf("%v :: expected takeoff %v landing %v got %v %v", test.t, test.starts[0],
+				test.starts[len(test.starts)-1], a.Takeoff, a.Landing)
+		}
+		for i, p := range a.Phases {
+			if p.Type != test.types[i] || !near(p.Start, test.starts[i]) {
+				t.Errorf("%v :: expected %v phase at %v got %v at %v", test.t, test.types[i],
+					test.starts[i], p.Type, p.Start)
+			}
+			if i > 0 && p.Start != a.Phases[i-1].End+1 {
+				t.Errorf("%v :: phase %v not contiguous :: %+v", test.t, i, a.Phases)
+			}
+		}
+		if a.Phases[0].Start != a.Takeoff || a.Phases[len(a.Phases)-1].End != a.Landing {
+			t.Errorf("%v :: phases do not cover the flight :: %+v", test.t, a.Phases)
+		}
+	}
+}
+
+// near checks an index is close enough to the expected one, as detection
+// relies on smoothed values.
+func near(v int, expected int) bool {
+	return v >= expected-10 && v <= expected+10
+}
+
+func TestAnalyzeNoFlight(t *testing.T) {
+	tests := []flight.Flight{
+		flight.NewFlight(),
+		newBuilder().ground(600).flight(),
+		// on a trailer, with no altitude change
+		newBuilder().ground(60).straight(600, 20, 0).ground(60).flight(),
+		// too short
+		newBuilder().ground(60).straight(40, 20, 2).ground(60).flight(),
+	}
+	for i, f := range tests {
+		a := Analyze(f)
+		if a.Takeoff != -1 || a.Landing != -1 || len(a.Phases) != 0 {
+			t.Errorf("%v :: expected no flight got %+v", i, a)
+		}
+	}
+}
+
+func TestAnalyzeRetrieve(t *testing.T) {
+	b := newBuilder().ground(60).straight(300, 30, 2.5).straight(300, 30, -2.5).ground(300)
+	b.straight(600, 25, 0)
+	a := Analyze(b.flight())
+	if !near(a.Takeoff, 60) || !near(a.Landing, 660) {
+		t.Errorf("expected takeoff 60 landing 660 got %v %v", a.Takeoff, a.Landing)
+	}
+}
+
+func TestAnalyzeSample(t *testing.T) {
+	content, err := ioutil.ReadFile("../netcoupe/t/sample-flight.igc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := flight.ParseIGC(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Analyze(f)
+	if a.Takeoff < 0 || a.Landing <= a.Takeoff || len(a.Phases) < 3 {
+		t.Fatalf("unexpected analysis :: %+v", a)
+	}
+	if a.Phases[0].Type != Launch {
+		t.Errorf("expected launch phase got %v", a.Phases[0].Type)
+	}
+	circling := 0
+	for _, p := range a.Phases {
+		if p.Type == Circling {
+			circling++
+		}
+	}
+	if circling == 0 {
+		t.Errorf("expected circling phases got none")
+	}
+}
+
+func TestPhaseTypeString(t *testing.T) {
+	types := map[PhaseType]string{Launch: "launch", Circling: "circling", Cruising: "cruising",
+		FinalGlide: "final glide", PhaseType(10): "unknown"}
+	for k, v := range types {
+		if k.String() != v {
+			t.Errorf("expected %v got %v", v, k.String())
+		}
+	}
+}
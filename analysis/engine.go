@@ -0,0 +1,169 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"time"
+)
+
+// EngineThresholds holds the sensor values (ENL and MOP extensions) above
+// which the engine is considered running.
+var EngineThresholds = map[string]float64{
+	"ENL": 500,
+	"MOP": 100,
+}
+
+const (
+	// minEngineDuration is the min duration (s) of an engine run.
+	minEngineDuration = 20.0
+	// maxEngineGap is the max duration (s) of a drop in the engine sensor
+	// values inside an engine run.
+	maxEngineGap = 10.0
+	// engineClimb is the min climb rate (m/s), flying straight, when
+	// detecting engine runs with no sensor data.
+	engineClimb = 1.5
+	// engineClimbWindow is the time window (s) used for the climb rate.
+	engineClimbWindow = 30.0
+	// minEngineClimbDuration is the min duration (s) of an engine run
+	// detected with no sensor data.
+	minEngineClimbDuration = 60.0
+	// selfLaunchClimb is the min climb rate (m/s), flying straight, of a
+	// self-launch with no sensor data, above the usual aerotow rates.
+	selfLaunchClimb = 3.0
+	// minSelfLaunchDuration is the min duration (s) of a self-launch with no
+	// sensor data, longer than a winch launch.
+	minSelfLaunchDuration = 120.0
+)
+
+// EngineRun is a period with the engine running.
+type EngineRun struct {
+	// Start and End are the indices of the first and last points.
+	Start     int
+	End       int
+	StartTime time.Time
+	EndTime   time.Time
+	// Altitude (m) when the engine was started.
+	Altitude float64
+	// Source is the extension (ENL or MOP) the run was detected from, or
+	// "climb" when detected from the climb pattern with no sensor data.
+	Source string
+}
+
+// RunsAfter returns the engine runs still going after time t, such as the
+// scored start of a task. Any run returned makes the flight non compliant.
+func (a Analysis) RunsAfter(t time.Time) []EngineRun {
+	var result []EngineRun
+	for _, r := range a.EngineRuns {
+		if r.EndTime.After(t) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// engineRuns returns the engine runs, from the sensor data in the points or,
+// with no sensor data, from the straight climbs.
+func (tr *track) engineRuns(phases []Phase) []EngineRun {
+	for _, code := range []string{"MOP", "ENL"} {
+		if tr.hasExtension(code) {
+			return tr.sensorRuns(code)
+		}
+	}
+	return tr.climbRuns(phases)
+}
+
+// hasExtension checks if any point has a valid value of the extension.
+func (tr *track) hasExtension(code string) bool {
+	for _, p := range tr.points {
+		if _, err := p.Extension(code); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sensorRuns returns the runs where the given extension is above its
+// threshold in EngineThresholds.
+func (tr *track) sensorRuns(code string) []EngineRun {
+	running := make([]bool, len(tr.points))
+	for i, p := range tr.points {
+		if v, err := p.Extension(code); err == nil && v.Value >= EngineThresholds[code] {
+			running[i] = true
+		}
+	}
+	return tr.runs(running, code, maxEngineGap, minEngineDuration)
+}
+
+// climbRuns returns the straight climbs, outside the circling phases, faster
+// than engineClimb. In the launch, only climbs faster than selfLaunchClimb
+// and longer than minSelfLaunchDuration are taken as a self-launch, as
+// slower climbs are usual in an aerotow and faster but shorter ones in a
+// winch launch.
+func (tr *track) climbRuns(phases []Phase) []EngineRun {
+	var result []EngineRun
+	running := make([]bool, len(tr.points))
+	for _, p := range phases {
+		climb := engineClimb
+		switch p.Type {
+		case Launch:
+			climb = selfLaunchClimb
+		case Cruising, FinalGlide:
+		default:
+			continue
+		}
+		for i := p.Start; i <= p.End; i++ {
+			running[i] = tr.rate(tr.alt, i, engineClimbWindow) >= climb &&
+				math.Abs(tr.turnRate(i)) < circlingRate
+		}
+		if p.Type == Launch {
+			result = append(result, tr.runs(running, "climb", maxEngineGap, minSelfLaunchDuration)...)
+			running = make([]bool, len(tr.points))
+		}
+	}
+	return append(result, tr.runs(running, "climb", maxEngineGap, minEngineClimbDuration)...)
+}
+
+// runs returns the engine runs in the given running flags, merging gaps up to
+// the given duration (s) and ignoring runs shorter than min (s).
+func (tr *track) runs(running []bool, source string, gap float64, min float64) []EngineRun {
+	var raw []EngineRun
+	for i, r := range running {
+		if !r {
+			continue
+		}
+		if len(raw) > 0 && tr.duration(raw[len(raw)-1].End, i) <= gap {
+			raw[len(raw)-1].End = i
+		} else {
+			raw = append(raw, EngineRun{Start: i, End: i})
+		}
+	}
+	var result []EngineRun
+	for _, r := range raw {
+		if tr.duration(r.Start, r.End) < min {
+			continue
+		}
+		r.StartTime, r.EndTime = tr.points[r.Start].Time, tr.points[r.End].Time
+		r.Altitude = tr.alt[r.Start]
+		r.Source = source
+		result = append(result, r)
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type engineTest struct {
+	t string
+	b *builder
+	// expected start index, altitude and source of each run
+	runs []EngineRun
+}
+
+var engineTests = []engineTest{
+	{
+		t: "self launch and engine run with enl",
+		b: newBuilder().extension("ENL", "020").ground(60).
+			extension("ENL", "900").straight(300, 30, 3).extension("ENL", "050").straight(60, 25, -1).
+			circle(300, 22, 2, 12).extension("ENL", "300").straight(600, 30, -1).
+			extension("ENL", "850").straight(120, 30, 2).extension("ENL", "010").
+			straight(600, 30, -1.5).straight(10, 10, 0).ground(60),
+		runs: []EngineRun{{Start: 60, Altitude: 450, Source: "ENL"}, {Start: 1320, Altitude: 1290, Source: "ENL"}},
+	},
+	{
+		t: "engine run with mop",
+		b: newBuilder().extension("MOP", "000").ground(60).straight(300, 30, 2.5).
+			straight(600, 30, -1).extension("MOP", "400").straight(30, 30, 2).
+			extension("MOP", "000").straight(300, 30, -1).ground(60),
+		runs: []EngineRun{{Start: 960, Altitude: 600, Source: "MOP"}},
+	},
+	{
+		t: "short enl noise",
+		b: newBuilder().extension("ENL", "020").ground(60).straight(300, 30, 2.5).
+			extension("ENL", "700").straight(10, 30, -1).extension("ENL", "020").
+			straight(300, 30, -1).ground(60),
+	},
+	{
+		t: "straight climb with no sensor",
+		b: newBuilder().ground(60).straight(300, 30, 2.5).straight(600, 30, -1).
+			straight(120, 30, 2).straight(300, 30, -1).ground(60),
+		runs: []EngineRun{{Start: 960, Altitude: 600, Source: "climb"}},
+	},
+	{
+		t: "self launch and engine run with no sensor",
+		b: newBuilder().ground(60).straight(300, 30, 4).straight(600, 30, -1).
+			straight(120, 30, 2).straight(300, 30, -1).ground(60),
+		// the climb rate is only known once in the air
+		runs: []EngineRun{{Start: 65, Altitude: 470, Source: "climb"},
+			{Start: 960, Altitude: 1050, Source: "climb"}},
+	},
+	{
+		t: "winch launch with no sensor",
+		b: newBuilder().ground(60).straight(40, 25, 10).straight(600, 30, -0.5).ground(60),
+	},
+	{
+		t: "thermal with no sensor",
+		b: newBuilder().ground(60).straight(300, 30, 2.5).straight(60, 25, -1).
+			circle(300, 22, 3, 12).straight(300, 30, -1).ground(60),
+	},
+}
+
+func TestEngineRuns(t *testing.T) {
+	for _, test := range engineTests {
+		a := Analyze(test.b.flight())
+		if len(a.EngineRuns) != len(test.runs) {
+			t.Errorf("%v :: expected %v runs got %+v", test.t, len(test.runs), a.EngineRuns)
+			continue
+		}
+		for i, r := range a.EngineRuns {
+			e := test.runs[i]
+			// climb detection is based on smoothed values
+			if !near(r.Start, e.Start) || math.Abs(r.Altitude-e.Altitude) > 30 || r.Source != e.Source {
+				t.Errorf("%v :: expected run %+v got %+v", test.t, e, r)
+			}
+			if r.End <= r.Start || !r.StartTime.Equal(test.b.points[r.Start].Time) ||
+				!r.EndTime.Equal(test.b.points[r.End].Time) {
+				t.Errorf("%v :: inconsistent run %+v", test.t, r)
+			}
+		}
+	}
+}
+
+func TestRunsAfter(t *testing.T) {
+	a := Analyze(engineTests[0].b.flight())
+	start := newBuilder().t
+	tests := []struct {
+		t    time.Time
+		runs int
+	}{
+		{start, 2}, {start.Add(10 * time.Minute), 1}, {start.Add(2 * time.Hour), 0},
+	}
+	for _, test := range tests {
+		if runs := a.RunsAfter(test.t); len(runs) != test.runs {
+			t.Errorf("expected %v runs after %v got %v", test.runs, test.t, len(runs))
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"time"
+
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// peakWindow is the time window (s) used for the peak climb rate.
+const peakWindow = 30.0
+
+// Direction is the turn direction when circling.
+type Direction int
+
+const (
+	// Left is a counter clockwise turn.
+	Left Direction = iota
+	// Right is a clockwise turn.
+	Right
+)
+
+func (d Direction) String() string {
+	if d == Right {
+		return "right"
+	}
+	return "left"
+}
+
+// Vector is a horizontal movement, with Speed (m/s) towards Direction (deg).
+type Vector struct {
+	Speed     float64
+	Direction float64
+}
+
+// Thermal holds the details of a circling phase.
+type Thermal struct {
+	// Start and End are the indices of the first and last points.
+	Start int
+	End   int
+	Entry time.Time
+	Exit  time.Time
+	// Gain is the altitude (m) gained, negative if lost.
+	Gain float64
+	// AvgClimb and PeakClimb are climb rates (m/s), the peak being the
+	// best over a period of peakWindow.
+	AvgClimb  float64
+	PeakClimb float64
+	Direction Direction
+	// Circles is the number of full circles.
+	Circles int
+	// Drift is the movement of the thermal, from the center of the first
+	// circle to the center of the last. It is zero with a single circle.
+	Drift Vector
+}
+
+// ThermalStats holds the thermal statistics of the whole flight.
+type ThermalStats struct {
+	// Circling is the percentage of the flight time spent circling.
+	Circling float64
+	// AvgClimb is the average climb rate (m/s) over all thermals.
+	AvgClimb float64
+	// Left and Right are the number of thermals in each direction.
+	Left  int
+	Right int
+}
+
+// thermals returns the details of each circling phase.
+func (tr *track) thermals(phases []Phase) []Thermal {
+	var result []Thermal
+	for _, p := range phases {
+		if p.Type != Circling {
+			continue
+		}
+		th := Thermal{
+			Start: p.Start, End: p.End,
+			Entry: tr.points[p.Start].Time, Exit: tr.points[p.End].Time,
+			Gain: tr.alt[p.End] - tr.alt[p.Start],
+		}
+		if d := tr.duration(p.Start, p.End); d > 0 {
+			th.AvgClimb = th.Gain / d
+		}
+		th.PeakClimb = tr.peakClimb(p.Start, p.End)
+		turned := tr.heading[p.End] - tr.heading[p.Start]
+		if turned > 0 {
+			th.Direction = Right
+		}
+		th.Circles = int(math.Abs(turned) / 360)
+		th.Drift = tr.drift(p.Start, p.End)
+		result = append(result, th)
+	}
+	return result
+}
+
+// peakClimb returns the best climb rate (m/s) between the given points, over
+// a period of peakWindow (or the whole period, when shorter).
+func (tr *track) peakClimb(start, end int) float64 {
+	if tr.duration(start, end) <= peakWindow {
+		if d := tr.duration(start, end); d > 0 {
+			return (tr.alt[end] - tr.alt[start]) / d
+		}
+		return 0
+	}
+	peak := math.Inf(-1)
+	j := start
+	for i := start; i <= end; i++ {
+		for j <= end && tr.duration(i, j) < peakWindow {
+			j++
+		}
+		if j > end {
+			break
+		}
+		peak = math.Max(peak, (tr.alt[j]-tr.alt[i])/tr.duration(i, j))
+	}
+	return peak
+}
+
+// circles returns the indices of the points starting each full circle
+// between the given points, and the point after the last circle.
+func (tr *track) circles(start, end int) []int {
+	result := []int{start}
+	for i := start; i <= end; i++ {
+		if math.Abs(tr.heading[i]-tr.heading[result[len(result)-1]]) >= 360 {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// center returns the mean position of the points from start to end
+// (exclusive), an approximation of the circle center.
+func (tr *track) center(start, end int) (float64, float64) {
+	var lat, lon float64
+	for i := start; i < end; i++ {
+		lat += tr.points[i].Latitude
+		lon += tr.points[i].Longitude
+	}
+	n := float64(end - start)
+	return lat / n, lon / n
+}
+
+// drift returns the movement between the centers of the first and last full
+// circles in the given section.
+func (tr *track) drift(start, end int) Vector {
+	c := tr.circles(start, end)
+	if len(c) < 3 {
+		return Vector{}
+	}
+	lat1, lon1 := tr.center(c[0], c[1])
+	lat2, lon2 := tr.center(c[len(c)-2], c[len(c)-1])
+	// time between the centers is the time between the middle of circles
+	dt := (tr.t[c[len(c)-2]] + tr.t[c[len(c)-1]] - tr.t[c[0]] - tr.t[c[1]]) / 2
+	if dt <= 0 {
+		return Vector{}
+	}
+	return Vector{
+		Speed:     spatial.Distance(lat1, lon1, lat2, lon2) / dt,
+		Direction: spatial.Bearing(lat1, lon1, lat2, lon2),
+	}
+}
+
+// thermalStats returns the flight statistics for the given thermals.
+func (tr *track) thermalStats(a Analysis) ThermalStats {
+	var stats ThermalStats
+	var circling, gain float64
+	for _, th := range a.Thermals {
+		circling += tr.duration(th.Start, th.End)
+		gain += th.Gain
+		if th.Direction == Right {
+			stats.Right++
+		} else {
+			stats.Left++
+		}
+	}
+	if circling > 0 {
+		stats.AvgClimb = gain / circling
+	}
+	if d := tr.duration(a.Takeoff, a.Landing); d > 0 {
+		stats.Circling = 100 * circling / d
+	}
+	return stats
+}
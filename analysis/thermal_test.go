@@ -0,0 +1,80 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestThermals(t *testing.T) {
+	a := Analyze(testFlight())
+	if len(a.Thermals) != 2 {
+		t.Fatalf("expected 2 thermals got %+v", a.Thermals)
+	}
+	expected := []struct {
+		gain      float64
+		climb     float64
+		direction Direction
+		circles   int
+	}{
+		{600, 2, Left, 10}, {270, 1.5, Right, 5},
+	}
+	for i, e := range expected {
+		th := a.Thermals[i]
+		if math.Abs(th.Gain-e.gain) > 25 || math.Abs(th.AvgClimb-e.climb) > 0.1 ||
+			math.Abs(th.PeakClimb-e.climb) > 0.1 || th.Direction != e.direction ||
+			math.Abs(float64(th.Circles-e.circles)) > 1 {
+			t.Errorf("expected thermal %+v got %+v", e, th)
+		}
+		if th.Exit.Sub(th.Entry) != time.Duration(th.End-th.Start)*time.Second {
+			t.Errorf("entry and exit times do not match indices :: %+v", th)
+		}
+		if th.Drift.Speed > 0.5 {
+			t.Errorf("expected no drift got %+v", th.Drift)
+		}
+	}
+	stats := a.ThermalStats
+	if math.Abs(stats.Circling-23.3) > 1 || math.Abs(stats.AvgClimb-1.8) > 0.1 ||
+		stats.Left != 1 || stats.Right != 1 {
+		t.Errorf("unexpected thermal stats :: %+v", stats)
+	}
+}
+
+func TestThermalDrift(t *testing.T) {
+	b := newBuilder().ground(60).straight(300, 30, 2.5).straight(60, 25, -1)
+	b.windSpeed, b.windDir = 5, 270
+	b.circle(300, 22, 2, 12).straight(300, 30, -1.5).straight(10, 10, 0).ground(60)
+	a := Analyze(b.flight())
+	if len(a.Thermals) != 1 {
+		t.Fatalf("expected 1 thermal got %+v", a.Thermals)
+	}
+	drift := a.Thermals[0].Drift
+	if math.Abs(drift.Speed-5) > 0.5 || math.Abs(math.Remainder(drift.Direction-90, 360)) > 5 {
+		t.Errorf("expected drift of 5 m/s towards 90 got %+v", drift)
+	}
+}
+
+func TestDirectionString(t *testing.T) {
+	if Left.String() != "left" || Right.String() != "right" {
+		t.Errorf("unexpected direction strings %v %v", Left, Right)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"time"
+
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// WindBand is the size (m) of the altitude bands for wind estimates.
+const WindBand = 500.0
+
+// Wind is a wind estimate, from a single circle.
+type Wind struct {
+	// Time is the middle of the circle.
+	Time time.Time
+	// Altitude is the mean altitude (m) in the circle, and Band the lower
+	// limit of its altitude band.
+	Altitude float64
+	Band     float64
+	// Speed (m/s) and Direction (deg) the wind is coming from.
+	Speed     float64
+	Direction float64
+}
+
+// winds returns the wind estimates for each full circle in the thermals.
+//
+// Each estimate is the mean of two methods: the circle drift, as a full circle
+// flown at constant airspeed ends displaced by the wind; and the ground speed
+// variation, with the wind speed being half the difference between the max
+// and min ground speeds, and coming from the track at the min ground speed.
+func (tr *track) winds(thermals []Thermal) []Wind {
+	var result []Wind
+	for _, th := range thermals {
+		c := tr.circles(th.Start, th.End)
+		for k := 0; k+1 < len(c); k++ {
+			if w, ok := tr.wind(c[k], c[k+1]); ok {
+				result = append(result, w)
+			}
+		}
+	}
+	return result
+}
+
+// wind returns the wind estimate for the circle between the given points.
+func (tr *track) wind(start, end int) (Wind, bool) {
+	dt := tr.duration(start, end)
+	if dt <= 0 {
+		return Wind{}, false
+	}
+	p1, p2 := tr.points[start], tr.points[end]
+	// drift, the wind coming from the opposite direction
+	drift := Vector{
+		Speed:     spatial.Distance(p1.Latitude, p1.Longitude, p2.Latitude, p2.Longitude) / dt,
+		Direction: spatial.Bearing(p2.Latitude, p2.Longitude, p1.Latitude, p1.Longitude),
+	}
+	// ground speed variation
+	min, max, into := math.Inf(1), math.Inf(-1), 0.0
+	var alt float64
+	for i := start + 1; i <= end; i++ {
+		alt += tr.alt[i]
+		d := tr.duration(i-1, i)
+		if d <= 0 {
+			continue
+		}
+		gs := (tr.dist[i] - tr.dist[i-1]) / d
+		if gs < min {
+			min, into = gs, tr.heading[i]
+		}
+		max = math.Max(max, gs)
+	}
+	speed := Vector{Speed: (max - min) / 2, Direction: math.Mod(into, 360)}
+	if math.IsInf(min, 0) {
+		speed = drift
+	}
+
+	// mean of both estimates
+	x := drift.Speed*math.Sin(spatial.Radians(drift.Direction)) + speed.Speed*math.Sin(spatial.Radians(speed.Direction))
+	y := drift.Speed*math.Cos(spatial.Radians(drift.Direction)) + speed.Speed*math.Cos(spatial.Radians(speed.Direction))
+	w := Wind{
+		Time:      p1.Time.Add(p2.Time.Sub(p1.Time) / 2),
+		Altitude:  alt / float64(end-start),
+		Speed:     math.Hypot(x, y) / 2,
+		Direction: math.Mod(spatial.Degrees(math.Atan2(x, y))+360, 360),
+	}
+	w.Band = math.Floor(w.Altitude/WindBand) * WindBand
+	return w, true
+}
+
+// WindAt returns the wind estimate closest to time t in the altitude band of
+// the given altitude, or the closest in time in any band if there is none.
+// It returns false if the analysis has no wind estimates.
+func (a Analysis) WindAt(t time.Time, altitude float64) (Wind, bool) {
+	band := math.Floor(altitude/WindBand) * WindBand
+	var best, bestBand *Wind
+	diff := func(w *Wind) time.Duration {
+		d := w.Time.Sub(t)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	for i := range a.Winds {
+		w := &a.Winds[i]
+		if best == nil || diff(w) < diff(best) {
+			best = w
+		}
+		if w.Band == band && (bestBand == nil || diff(w) < diff(bestBand)) {
+			bestBand = w
+		}
+	}
+	if bestBand != nil {
+		return *bestBand, true
+	}
+	if best != nil {
+		return *best, true
+	}
+	return Wind{}, false
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func windFlight() *builder {
+	b := newBuilder().ground(60).straight(300, 30, 2.5).straight(60, 25, -1)
+	b.windSpeed, b.windDir = 5, 270
+	b.circle(300, 22, 2, 12).straight(300, 30, -1.5)
+	b.windSpeed, b.windDir = 10, 30
+	b.circle(240, 22, 2.5, -12)
+	b.windSpeed = 0
+	b.straight(600, 30, -1.5).straight(10, 10, 0).ground(60)
+	return b
+}
+
+func TestWinds(t *testing.T) {
+	a := Analyze(windFlight().flight())
+	if len(a.Thermals) != 2 || len(a.Winds) < 15 {
+		t.Fatalf("expected 2 thermals and wind estimates got %v %v", len(a.Thermals), len(a.Winds))
+	}
+	for _, w := range a.Winds {
+		speed, dir, entry := 5.0, 270.0, a.Thermals[0].Entry
+		if w.Time.After(a.Thermals[1].Entry) {
+			speed, dir, entry = 10, 30, a.Thermals[1].Entry
+		}
+		if w.Band != math.Floor(w.Altitude/WindBand)*WindBand {
+			t.Errorf("wrong band for altitude %v :: %v", w.Altitude, w.Band)
+		}
+		if w.Time.Before(a.Thermals[0].Entry) {
+			t.Errorf("unexpected wind time %v", w.Time)
+		}
+		// skip the first circle, where the thermal entry is detected early
+		if w.Time.Sub(entry) < time.Minute {
+			continue
+		}
+		// ground speed variation has the precision of the turn between points
+		if math.Abs(w.Speed-speed) > 0.5 || math.Abs(math.Remainder(w.Direction-dir, 360)) > 8 {
+			t.Errorf("expected wind %v from %v got %+v", speed, dir, w)
+		}
+	}
+}
+
+func TestWindAt(t *testing.T) {
+	a := Analysis{}
+	if _, ok := a.WindAt(time.Now(), 1000); ok {
+		t.Errorf("expected no wind estimate")
+	}
+	base := time.Date(2015, 6, 20, 12, 0, 0, 0, time.UTC)
+	a.Winds = []Wind{
+		{Time: base, Altitude: 1200, Band: 1000, Speed: 1},
+		{Time: base.Add(time.Hour), Altitude: 1800, Band: 1500, Speed: 2},
+		{Time: base.Add(2 * time.Hour), Altitude: 1100, Band: 1000, Speed: 3},
+	}
+	tests := []struct {
+		t        time.Time
+		altitude float64
+		speed    float64
+	}{
+		{base, 1000, 1}, {base.Add(time.Hour), 1000, 1}, {base.Add(100 * time.Minute), 1400, 3},
+		{base, 1600, 2}, {base.Add(-time.Hour), 3000, 1},
+	}
+	for _, test := range tests {
+		w, ok := a.WindAt(test.t, test.altitude)
+		if !ok || w.Speed != test.speed {
+			t.Errorf("expected wind %v at %v %v got %+v", test.speed, test.t, test.altitude, w)
+		}
+	}
+}
@@ -38,9 +38,11 @@ import (
 	"os"
 	"os/user"
 
+	"github.com/rochaporto/ezgliding/cup"
 	"github.com/rochaporto/ezgliding/fusiontables"
 	"github.com/rochaporto/ezgliding/mock"
 	"github.com/rochaporto/ezgliding/netcoupe"
+	"github.com/rochaporto/ezgliding/openaip"
 	"github.com/rochaporto/ezgliding/soaringweb"
 	"github.com/rochaporto/ezgliding/web"
 	"github.com/rochaporto/ezgliding/welt2000"
@@ -71,9 +73,11 @@ type Global struct {
 // Config holds all the config information for ezgliding plugins and apps.
 type Config struct {
 	Global       Global
+	Cup          cup.Config
 	FusionTables fusiontables.Config
 	Mock         mock.Config
 	Netcoupe     netcoupe.Config
+	OpenAIP      openaip.Config
 	SoaringWeb   soaringweb.Config
 	Web          web.Config
 	Welt2000     welt2000.Config
@@ -0,0 +1,493 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package cup provides functionality to read and write waypoint, airfield
+// and task information in the SeeYou CUP format.
+//
+// The format specification is available at:
+//
+//	http://download.naviter.com/docs/cup_format.pdf
+//
+// Airfields are the records with styles 2 (grass runway), 3 (outlanding),
+// 4 (gliding airfield) and 5 (solid runway), mapped to the airfield flags.
+// All other records are waypoints, with the CUP style kept in Flags.
+package cup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/util"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+// ID for this plugin implementation.
+const (
+	ID string = "cup"
+)
+
+// CUP styles for airfields.
+const (
+	GrassStyle       = 2
+	OutlandingStyle  = 3
+	GliderSiteStyle  = 4
+	SolidRunwayStyle = 5
+)
+
+// header is the column header line written in CUP files.
+const header = "name,code,country,lat,lon,elev,style,rwdir,rwlen,freq,desc"
+
+// columns are the default CUP columns, used when the file has no header.
+var columns = strings.Split(header, ",")
+
+// legacyColumns maps the column names in the legacy SeeYou header (Title,
+// Code, Country, Latitude, ...) to the current ones.
+var legacyColumns = map[string]string{
+	"title":       "name",
+	"latitude":    "lat",
+	"longitude":   "lon",
+	"elevation":   "elev",
+	"direction":   "rwdir",
+	"length":      "rwlen",
+	"frequency":   "freq",
+	"description": "desc",
+}
+
+// File holds the content of a CUP file.
+type File struct {
+	Waypoints []waypoint.Waypoint
+	Airfields []airfield.Airfield
+	Tasks     []Task
+}
+
+// Config holds all config information for the cup plugin.
+type Config struct {
+	// Location is the path or http url of the CUP file.
+	Location string
+}
+
+// Cup is the plugin implementation for CUP files, for airfields and
+// waypoints.
+type Cup struct {
+	Config
+}
+
+// New returns a new instance of Cup.
+func New(cfg Config) (*Cup, error) {
+	c := Cup{Config: cfg}
+	glog.V(20).Infof("Plugin cup initialized :: %+v", c)
+	return &c, nil
+}
+
+// GetAirfield follows airfield.GetAirfield().
+// Passing no regions returns the airfields in all regions.
+func (c *Cup) GetAirfield(regions []string, updatedSince time.Time) ([]airfield.Airfield, error) {
+	glog.V(10).Infof("GetAirfield with regions %v and updatedSince %v", regions, updatedSince)
+	f, err := c.fetch(updatedSince)
+	if err != nil {
+		return nil, err
+	}
+	var result []airfield.Airfield
+	for _, a := range f.Airfields {
+		if inRegions(a.Region, regions) {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+// PutAirfield follows airfield.PutAirfield().
+// It replaces the airfields in the file, keeping waypoints and tasks.
+func (c *Cup) PutAirfield(airfields []airfield.Airfield) error {
+	f, err := c.load()
+	if err != nil {
+		return err
+	}
+	f.Airfields = airfields
+	return c.save(f)
+}
+
+// GetWaypoint follows waypoint.GetWaypoint().
+// Passing no regions returns the waypoints in all regions.
+func (c *Cup) GetWaypoint(regions []string, updatedSince time.Time) ([]waypoint.Waypoint, error) {
+	glog.V(10).Infof("GetWaypoint with regions %v and updatedSince %v", regions, updatedSince)
+	f, err := c.fetch(updatedSince)
+	if err != nil {
+		return nil, err
+	}
+	var result []waypoint.Waypoint
+	for _, w := range f.Waypoints {
+		if inRegions(w.Region, regions) {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+// PutWaypoint follows waypoint.PutWaypoint().
+// It replaces the waypoints in the file, keeping airfields and tasks.
+func (c *Cup) PutWaypoint(waypoints []waypoint.Waypoint) error {
+	f, err := c.load()
+	if err != nil {
+		return err
+	}
+	f.Waypoints = waypoints
+	return c.save(f)
+}
+
+func inRegions(region string, regions []string) bool {
+	if len(regions) == 0 {
+		return true
+	}
+	for _, r := range regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch parses the file at Location, if modified after the given time.
+// The modification time is set as the Update of all airfields and
+// waypoints.
+func (c *Cup) fetch(updatedSince time.Time) (File, error) {
+	var r io.Reader
+	var modified time.Time
+	resp, err := http.Get(c.Location)
+	if err == nil { // case http
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return File{}, fmt.Errorf("failed to fetch %v :: %v", c.Location, resp.Status)
+		}
+		modified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+		r = resp.Body
+	} else { // case file
+		file, err := os.Open(c.Location)
+		if err != nil {
+			return File{}, err
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil {
+			modified = info.ModTime()
+		}
+		r = file
+	}
+	if !modified.IsZero() && !modified.After(updatedSince) {
+		return File{}, nil
+	}
+	f, err := Parse(r)
+	if err != nil {
+		return File{}, err
+	}
+	for i := range f.Airfields {
+		f.Airfields[i].Update = modified
+	}
+	for i := range f.Waypoints {
+		f.Waypoints[i].Update = modified
+	}
+	return f, nil
+}
+
+// load parses the local file at Location, returning an empty File if it
+// does not exist yet.
+func (c *Cup) load() (File, error) {
+	file, err := os.Open(c.Location)
+	if os.IsNotExist(err) {
+		return File{}, nil
+	} else if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// save writes the given content to the local file at Location.
+func (c *Cup) save(f File) error {
+	file, err := os.Create(c.Location)
+	if err != nil {
+		return err
+	}
+	if err = Write(file, f); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Parse parses the CUP content in r.
+func Parse(r io.Reader) (File, error) {
+	f := File{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	var tasks [][]string
+	names := columns
+	inTasks := false
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return File{}, fmt.Errorf("failed to parse line %v :: %v", line, err)
+		}
+		switch {
+		case len(record) == 1 && strings.HasPrefix(record[0], "-----Related Tasks"):
+			inTasks = true
+		case inTasks:
+			tasks = append(tasks, record)
+		case line == 1 && (strings.EqualFold(record[0], "name") || strings.EqualFold(record[0], "title")):
+			names = make([]string, len(record))
+			for i, n := range record {
+				names[i] = strings.ToLower(strings.TrimSpace(n))
+				if name, ok := legacyColumns[names[i]]; ok {
+					names[i] = name
+				}
+			}
+		default:
+			values := map[string]string{}
+			for i, v := range record {
+				if i < len(names) {
+					values[names[i]] = strings.TrimSpace(v)
+				}
+			}
+			if err := f.parseRecord(values); err != nil {
+				return File{}, fmt.Errorf("failed to parse line %v :: %v", line, err)
+			}
+		}
+	}
+	var err error
+	f.Tasks, err = parseTasks(tasks, f.points())
+	return f, err
+}
+
+// parseRecord parses a waypoint or airfield record, given as values keyed by
+// their column name.
+func (f *File) parseRecord(values map[string]string) error {
+	lat, err := parseCoordinate(values["lat"], 2)
+	if err != nil {
+		return err
+	}
+	lon, err := parseCoordinate(values["lon"], 3)
+	if err != nil {
+		return err
+	}
+	elevation := 0.0
+	if values["elev"] != "" {
+		if elevation, err = parseLength(values["elev"]); err != nil {
+			return err
+		}
+	}
+	style := 0
+	if values["style"] != "" {
+		if style, err = strconv.Atoi(values["style"]); err != nil {
+			return fmt.Errorf("invalid style '%v' :: %v", values["style"], err)
+		}
+	}
+	id := values["code"]
+	if id == "" {
+		id = values["name"]
+	}
+
+	if style < GrassStyle || style > SolidRunwayStyle {
+		f.Waypoints = append(f.Waypoints, waypoint.Waypoint{
+			ID: id, Name: values["name"], Description: values["desc"], Region: values["country"],
+			Flags: style, Elevation: int(math.Floor(elevation + 0.5)), Latitude: lat, Longitude: lon,
+		})
+		return nil
+	}
+	a := airfield.Airfield{
+		ID: id, ShortName: values["code"], Name: values["name"], Region: values["country"],
+		Flags: styleFlags[style], Elevation: int(math.Floor(elevation + 0.5)), Latitude: lat, Longitude: lon,
+	}
+	if values["rwdir"] != "" {
+		dir, err := strconv.Atoi(values["rwdir"])
+		if err != nil {
+			return fmt.Errorf("invalid runway direction '%v' :: %v", values["rwdir"], err)
+		}
+		a.Runway = airfield.RunwayForHeading(dir)
+	}
+	if values["rwlen"] != "" {
+		length, err := parseLength(values["rwlen"])
+		if err != nil {
+			return err
+		}
+		a.Length = int(math.Floor(length + 0.5))
+	}
+	if values["freq"] != "" {
+		if a.Frequency, err = strconv.ParseFloat(values["freq"], 64); err != nil {
+			return fmt.Errorf("invalid frequency '%v' :: %v", values["freq"], err)
+		}
+	}
+	f.Airfields = append(f.Airfields, a)
+	return nil
+}
+
+// styleFlags maps the airfield styles to airfield flags.
+var styleFlags = map[int]int{
+	GrassStyle:       airfield.Grass,
+	OutlandingStyle:  airfield.Outlanding,
+	GliderSiteStyle:  airfield.GliderSite,
+	SolidRunwayStyle: airfield.Asphalt,
+}
+
+// airfieldStyle returns the style for the given airfield flags.
+func airfieldStyle(flags int) int {
+	switch {
+	case flags&airfield.Outlanding != 0:
+		return OutlandingStyle
+	case flags&airfield.GliderSite != 0:
+		return GliderSiteStyle
+	case flags&(airfield.Asphalt|airfield.Concrete) != 0:
+		return SolidRunwayStyle
+	}
+	return GrassStyle
+}
+
+// runwayDirection returns the direction (deg) of the first runway designator.
+func runwayDirection(rw string) (int, error) {
+	if len(rw) < 2 {
+		return 0, fmt.Errorf("invalid runway '%v'", rw)
+	}
+	d, err := strconv.Atoi(rw[0:2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid runway '%v' :: %v", rw, err)
+	}
+	return d * 10, nil
+}
+
+// lengthUnits holds the meters of each length unit, the longest suffixes
+// first.
+var lengthUnits = []struct {
+	suffix string
+	meters float64
+}{
+	{"km", 1000}, {"nm", 1852}, {"ml", 1609.344}, {"ft", util.Foot}, {"m", 1},
+}
+
+// parseLength parses lengths like 504.0m, 1650ft or 1.2nm into meters.
+// Values with no unit are in meters.
+func parseLength(s string) (float64, error) {
+	value, meters := strings.ToLower(strings.TrimSpace(s)), 1.0
+	for _, u := range lengthUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, meters = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.meters
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid length '%v' :: %v", s, err)
+	}
+	return v * meters, nil
+}
+
+// parseCoordinate parses coordinates like 4621.379N or 01410.467E, with the
+// given number of digits for degrees.
+func parseCoordinate(s string, digits int) (float64, error) {
+	if len(s) < digits+4 || s[digits+2] != '.' {
+		return 0, fmt.Errorf("invalid coordinate '%v'", s)
+	}
+	hemisphere := s[len(s)-1]
+	degrees, err := strconv.Atoi(s[:digits])
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate '%v' :: %v", s, err)
+	}
+	minutes, err := strconv.ParseFloat(s[digits:len(s)-1], 64)
+	if err != nil || minutes >= 60 {
+		return 0, fmt.Errorf("invalid coordinate '%v' :: %v", s, err)
+	}
+	v := float64(degrees) + minutes/60
+	switch hemisphere {
+	case 'N', 'E':
+		return v, nil
+	case 'S', 'W':
+		return -v, nil
+	}
+	return 0, fmt.Errorf("invalid coordinate hemisphere '%v'", s)
+}
+
+// formatCoordinate formats the given coordinate like 4621.379N, for
+// latitudes, or 01410.467E, for longitudes.
+func formatCoordinate(v float64, latitude bool) string {
+	hemisphere, digits := "N", 2
+	if latitude && v < 0 {
+		hemisphere = "S"
+	} else if !latitude {
+		hemisphere, digits = "E", 3
+		if v < 0 {
+			hemisphere = "W"
+		}
+	}
+	// thousandths of minutes
+	m := int64(math.Floor(math.Abs(v)*60000 + 0.5))
+	return fmt.Sprintf("%0*d%02d.%03d%v", digits, m/60000, m%60000/1000, m%1000, hemisphere)
+}
+
+// Write writes the given content to w in the CUP format.
+func Write(w io.Writer, f File) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	cw.Write(columns)
+	for _, a := range f.Airfields {
+		rwdir := ""
+		if a.Runway != "" {
+			dir, err := runwayDirection(a.Runway)
+			if err != nil {
+				return err
+			}
+			rwdir = strconv.Itoa(dir)
+		}
+		record := []string{a.Name, a.ShortName, a.Region, formatCoordinate(a.Latitude, true),
+			formatCoordinate(a.Longitude, false), fmt.Sprintf("%vm", a.Elevation),
+			strconv.Itoa(airfieldStyle(a.Flags)), rwdir, "", "", ""}
+		if a.Length > 0 {
+			record[8] = fmt.Sprintf("%vm", a.Length)
+		}
+		if a.Frequency > 0 {
+			record[9] = fmt.Sprintf("%.3f", a.Frequency)
+		}
+		cw.Write(record)
+	}
+	for _, wp := range f.Waypoints {
+		cw.Write([]string{wp.Name, wp.ID, wp.Region, formatCoordinate(wp.Latitude, true),
+			formatCoordinate(wp.Longitude, false), fmt.Sprintf("%vm", wp.Elevation),
+			strconv.Itoa(wp.Flags), "", "", "", wp.Description})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if len(f.Tasks) == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(w, "-----Related Tasks-----\r\n"); err != nil {
+		return err
+	}
+	return writeTasks(w, f.Tasks)
+}
@@ -0,0 +1,266 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package cup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+var testAirfields = []airfield.Airfield{
+	{ID: "LSTR", ShortName: "LSTR", Name: "Montricher", Region: "CH", Flags: airfield.GliderSite,
+		Length: 550, Elevation: 667, Runway: "2810", Frequency: 122.475,
+		Latitude: 46.58971666666667, Longitude: 6.40195},
+	{ID: "LSGG", ShortName: "LSGG", Name: "Geneve", Region: "CH", Flags: airfield.Asphalt,
+		Length: 3900, Elevation: 411, Runway: "2204", Frequency: 118.7,
+		Latitude: 46.238333333333336, Longitude: 6.108883333333333},
+	{ID: "BOVET", ShortName: "BOVET", Name: "Champ Bovet", Region: "CH", Flags: airfield.Outlanding,
+		Elevation: 610, Latitude: 46.666666666666664, Longitude: 6.5},
+}
+
+var testWaypoints = []waypoint.Waypoint{
+	{ID: "DVAUL", Name: "Dent de Vaulion", Description: "Summit", Region: "CH", Flags: 7,
+		Elevation: 1483, Latitude: 46.686, Longitude: 6.353166666666667},
+	{ID: "YVBRI", Name: "Yverdon Bridge", Region: "CH", Flags: 1,
+		Elevation: 435, Latitude: 46.775, Longitude: 6.641666666666667},
+}
+
+func TestParse(t *testing.T) {
+	file, err := os.Open("t/test.cup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	f, err := Parse(file)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if !reflect.DeepEqual(f.Airfields, testAirfields) {
+		t.Errorf("expected airfields %+v got %+v", testAirfields, f.Airfields)
+	}
+	if !reflect.DeepEqual(f.Waypoints, testWaypoints) {
+		t.Errorf("expected waypoints %+v got %+v", testWaypoints, f.Waypoints)
+	}
+	if len(f.Tasks) != 2 {
+		t.Errorf("expected 2 tasks got %v", len(f.Tasks))
+	}
+}
+
+func TestParseNoHeader(t *testing.T) {
+	f, err := Parse(strings.NewReader(`"Vaulion",VAUL,CH,4641.160N,00621.190E,1483m,7,,,,`))
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(f.Waypoints) != 1 || f.Waypoints[0].ID != "VAUL" || f.Waypoints[0].Elevation != 1483 {
+		t.Errorf("unexpected waypoints :: %+v", f.Waypoints)
+	}
+}
+
+func TestParseLegacyHeader(t *testing.T) {
+	file, err := os.Open("t/legacy.cup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	f, err := Parse(file)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if !reflect.DeepEqual(f.Airfields, testAirfields[:1]) {
+		t.Errorf("expected airfields %+v got %+v", testAirfields[:1], f.Airfields)
+	}
+	if !reflect.DeepEqual(f.Waypoints, testWaypoints[:1]) {
+		t.Errorf("expected waypoints %+v got %+v", testWaypoints[:1], f.Waypoints)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		`"A",A,CH,4641.160X,00621.190E,1483m,7,,,,`,
+		`"A",A,CH,4641.160N,00621E,1483m,7,,,,`,
+		`"A",A,CH,4661.160N,00621.190E,1483m,7,,,,`,
+		`"A",A,CH,4641.160N,00621.190E,1483yd,7,,,,`,
+		`"A",A,CH,4641.160N,00621.190E,1483m,x,,,,`,
+		`"A",A,CH,4641.160N,00621.190E,1483m,2,north,,,`,
+		`"A",A,CH,4641.160N,00621.190E,1483m,2,,long,,`,
+		`"A",A,CH,4641.160N,00621.190E,1483m,2,,,freq,`,
+	}
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(test)); err == nil {
+			t.Errorf("expected error parsing '%v' but got success", test)
+		}
+	}
+}
+
+func TestParseLength(t *testing.T) {
+	tests := map[string]float64{"504.0m": 504, "504": 504, "1.5km": 1500, "2nm": 3704,
+		"1ml": 1609.344, "1000ft": 304.8, "1000 FT": 304.8}
+	for s, e := range tests {
+		v, err := parseLength(s)
+		if err != nil || math.Abs(v-e) > 1e-9 {
+			t.Errorf("expected %v for '%v' got %v :: %v", e, s, v, err)
+		}
+	}
+}
+
+func TestFormatCoordinate(t *testing.T) {
+	tests := []struct {
+		v        float64
+		latitude bool
+		s        string
+	}{
+		{46.58971666666667, true, "4635.383N"},
+		{-33.5, true, "3330.000S"},
+		{6.40195, false, "00624.117E"},
+		{-70.9999999, false, "07100.000W"},
+	}
+	for _, test := range tests {
+		s := formatCoordinate(test.v, test.latitude)
+		if s != test.s {
+			t.Errorf("expected %v got %v", test.s, s)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, File{Airfields: testAirfields, Waypoints: testWaypoints}); err != nil {
+		t.Fatalf("failed to write :: %v", err)
+	}
+	f, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written content :: %v", err)
+	}
+	for i := range f.Airfields {
+		a, e := f.Airfields[i], testAirfields[i]
+		if math.Abs(a.Latitude-e.Latitude) > 1e-6 || math.Abs(a.Longitude-e.Longitude) > 1e-6 {
+			t.Errorf("expected position %v %v got %v %v", e.Latitude, e.Longitude, a.Latitude, a.Longitude)
+		}
+		a.Latitude, a.Longitude = e.Latitude, e.Longitude
+		if !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %+v got %+v", e, a)
+		}
+	}
+	for i := range f.Waypoints {
+		w, e := f.Waypoints[i], testWaypoints[i]
+		w.Latitude, w.Longitude = e.Latitude, e.Longitude
+		if !reflect.DeepEqual(w, e) {
+			t.Errorf("expected %+v got %+v", e, w)
+		}
+	}
+}
+
+func TestWriteStyles(t *testing.T) {
+	tests := map[int]string{airfield.Concrete: "5", airfield.GliderSite | airfield.Grass: "4",
+		airfield.Outlanding | airfield.Asphalt: "3", airfield.Grass: "2", 0: "2"}
+	for flags, style := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, File{Airfields: []airfield.Airfield{{Name: "A", Flags: flags}}}); err != nil {
+			t.Fatalf("failed to write :: %v", err)
+		}
+		record := strings.Split(strings.Split(buf.String(), "\r\n")[1], ",")
+		if record[6] != style {
+			t.Errorf("expected style %v for flags %v got %v", style, flags, record[6])
+		}
+	}
+}
+
+func TestGetAirfield(t *testing.T) {
+	c, _ := New(Config{Location: "t/test.cup"})
+	tests := []struct {
+		regions []string
+		since   time.Time
+		n       int
+	}{
+		{nil, time.Time{}, 3},
+		{[]string{"CH"}, time.Time{}, 3},
+		{[]string{"FR"}, time.Time{}, 0},
+		{nil, time.Now().Add(time.Hour), 0},
+	}
+	for _, test := range tests {
+		airfields, err := c.GetAirfield(test.regions, test.since)
+		if err != nil {
+			t.Errorf("failed to get airfields :: %v", err)
+			continue
+		}
+		if len(airfields) != test.n {
+			t.Errorf("expected %v airfields for %v %v got %v", test.n, test.regions, test.since, len(airfields))
+		}
+		for _, a := range airfields {
+			if a.Update.IsZero() {
+				t.Errorf("expected update time set got %+v", a)
+			}
+		}
+	}
+}
+
+func TestGetWaypoint(t *testing.T) {
+	c, _ := New(Config{Location: "t/test.cup"})
+	waypoints, err := c.GetWaypoint([]string{"CH"}, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get waypoints :: %v", err)
+	}
+	if len(waypoints) != 2 {
+		t.Errorf("expected 2 waypoints got %v", len(waypoints))
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	c, _ := New(Config{Location: "t/missing.cup"})
+	if _, err := c.GetAirfield(nil, time.Time{}); err == nil {
+		t.Errorf("expected error but got success")
+	}
+	if _, err := c.GetWaypoint(nil, time.Time{}); err == nil {
+		t.Errorf("expected error but got success")
+	}
+}
+
+func TestPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, _ := New(Config{Location: filepath.Join(dir, "put.cup")})
+	if err = c.PutAirfield(testAirfields); err != nil {
+		t.Fatalf("failed to put airfields :: %v", err)
+	}
+	if err = c.PutWaypoint(testWaypoints); err != nil {
+		t.Fatalf("failed to put waypoints :: %v", err)
+	}
+	airfields, err := c.GetAirfield(nil, time.Time{})
+	if err != nil || len(airfields) != len(testAirfields) {
+		t.Errorf("expected %v airfields got %v :: %v", len(testAirfields), len(airfields), err)
+	}
+	waypoints, err := c.GetWaypoint(nil, time.Time{})
+	if err != nil || len(waypoints) != len(testWaypoints) {
+		t.Errorf("expected %v waypoints got %v :: %v", len(testWaypoints), len(waypoints), err)
+	}
+}
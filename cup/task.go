@@ -0,0 +1,370 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package cup
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rochaporto/ezgliding/spatial"
+	"github.com/rochaporto/ezgliding/task"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+// Task is a task in the related tasks section of a CUP file.
+//
+// Takeoff and Landing are the names of the takeoff and landing points,
+// which are not part of the task. The task points must exist in the
+// waypoints or airfields of the file, matching by name.
+type Task struct {
+	Name    string
+	Takeoff string
+	Landing string
+	task.Task
+}
+
+// CUP observation zone styles.
+const (
+	fixedStyle     = 0
+	symmetricStyle = 1
+	nextStyle      = 2
+	previousStyle  = 3
+	startStyle     = 4
+)
+
+// obsZone holds the values of an ObsZone line.
+type obsZone struct {
+	style          int
+	r1, a1, r2, a2 float64
+	a12            float64
+	line           bool
+}
+
+// zone returns the observation zone described in o, for the task point at
+// index n in tps (start, turnpoints and finish).
+//
+// Zones with A1 of 180 are cylinders, or keyholes when an inner R2 with A2 of
+// 180 is given. Symmetric 45 degree sectors are FAI sectors, and other
+// sectors span A1 on each side of their direction, with R2 as the inner
+// radius when A2 equals A1.
+func (o obsZone) zone(tps []task.TaskPoint, n int) (task.Zone, error) {
+	switch {
+	case o.line:
+		return task.Zone{Type: task.Line, Radius: o.r1}, nil
+	case o.r2 > 0 && o.a2 >= 180 && o.a1 < 180:
+		return task.Zone{Type: task.Keyhole, Radius: o.r1, InnerRadius: o.r2}, nil
+	case o.a1 >= 180:
+		return task.Zone{Type: task.Cylinder, Radius: o.r1}, nil
+	case o.style == symmetricStyle && o.a1 == 45:
+		return task.Zone{Type: task.FAISector, Radius: o.r1}, nil
+	}
+	direction, err := o.direction(tps, n)
+	if err != nil {
+		return task.Zone{}, err
+	}
+	z := task.Zone{Type: task.Sector, Radius: o.r1,
+		Radial1: math.Mod(direction-o.a1+360, 360), Radial2: math.Mod(direction+o.a1, 360)}
+	if o.a2 == o.a1 {
+		z.InnerRadius = o.r2
+	}
+	return z, nil
+}
+
+// direction returns the direction (deg) sectors point to, from the style in
+// o: A12 for fixed sectors, away from the course for symmetric ones (back
+// at the start and ahead at the finish), or to the next, previous or start
+// point.
+func (o obsZone) direction(tps []task.TaskPoint, n int) (float64, error) {
+	bearing := func(i int) float64 {
+		return spatial.Bearing(tps[n].Waypoint.Latitude, tps[n].Waypoint.Longitude,
+			tps[i].Waypoint.Latitude, tps[i].Waypoint.Longitude)
+	}
+	last := len(tps) - 1
+	switch {
+	case o.style == fixedStyle:
+		return o.a12, nil
+	case o.style == symmetricStyle && n == 0:
+		return math.Mod(bearing(1)+180, 360), nil
+	case o.style == symmetricStyle && n == last:
+		return math.Mod(bearing(n-1)+180, 360), nil
+	case o.style == symmetricStyle:
+		// bisector of the legs to the previous and next points, outside
+		in, out := spatial.Radians(bearing(n-1)), spatial.Radians(bearing(n+1))
+		x, y := math.Sin(in)+math.Sin(out), math.Cos(in)+math.Cos(out)
+		if math.Hypot(x, y) < 1e-9 {
+			// out and return, the sector points ahead
+			return math.Mod(bearing(n-1)+180, 360), nil
+		}
+		return math.Mod(spatial.Degrees(math.Atan2(x, y))+180+360, 360), nil
+	case o.style == nextStyle && n < last:
+		return bearing(n + 1), nil
+	case o.style == previousStyle && n > 0:
+		return bearing(n - 1), nil
+	case o.style == startStyle && n > 0:
+		return bearing(0), nil
+	}
+	return 0, fmt.Errorf("invalid observation zone style %v for task point %v", o.style, n)
+}
+
+// newObsZone returns the ObsZone values for z, at the given style.
+func newObsZone(z task.Zone, style int) (obsZone, error) {
+	o := obsZone{style: style, r1: z.Radius}
+	switch z.Type {
+	case task.Line:
+		o.a1, o.line = 180, true
+	case task.Cylinder:
+		o.a1 = 180
+	case task.FAISector:
+		o.style, o.a1 = symmetricStyle, 45
+	case task.Keyhole:
+		o.style, o.a1, o.r2, o.a2 = symmetricStyle, 45, z.InnerRadius, 180
+	case task.Sector:
+		o.style = fixedStyle
+		o.a1 = math.Mod(z.Radial2-z.Radial1+360, 360) / 2
+		o.a12 = math.Mod(z.Radial1+o.a1, 360)
+		if z.InnerRadius > 0 {
+			o.r2, o.a2 = z.InnerRadius, o.a1
+		}
+	default:
+		return o, fmt.Errorf("zone type not supported :: %v", z.Type)
+	}
+	return o, nil
+}
+
+// parseObsZone parses the fields of an ObsZone line, returning the index of
+// the task point it applies to.
+func parseObsZone(fields []string) (int, obsZone, error) {
+	o := obsZone{}
+	n := -1
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return 0, o, fmt.Errorf("invalid observation zone field '%v'", f)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "obszone":
+			n, err = strconv.Atoi(kv[1])
+		case "style":
+			o.style, err = strconv.Atoi(kv[1])
+		case "r1":
+			o.r1, err = parseLength(kv[1])
+		case "a1":
+			o.a1, err = strconv.ParseFloat(kv[1], 64)
+		case "r2":
+			o.r2, err = parseLength(kv[1])
+		case "a2":
+			o.a2, err = strconv.ParseFloat(kv[1], 64)
+		case "a12":
+			o.a12, err = strconv.ParseFloat(kv[1], 64)
+		case "line":
+			o.line = kv[1] == "1"
+		}
+		if err != nil {
+			return 0, o, fmt.Errorf("invalid observation zone field '%v' :: %v", f, err)
+		}
+	}
+	if n < 0 {
+		return 0, o, fmt.Errorf("observation zone with no index :: %v", fields)
+	}
+	return n, o, nil
+}
+
+// parseDuration parses durations like 01:45:00.
+func parseDuration(s string) (time.Duration, error) {
+	var h, m, sec int
+	if _, err := fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return 0, fmt.Errorf("invalid duration '%v' :: %v", s, err)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second, nil
+}
+
+// points returns the waypoints and airfields in the file, by name.
+func (f File) points() map[string]waypoint.Waypoint {
+	result := make(map[string]waypoint.Waypoint)
+	for _, a := range f.Airfields {
+		result[a.Name] = waypoint.Waypoint{ID: a.ID, Name: a.Name, Region: a.Region,
+			Elevation: a.Elevation, Latitude: a.Latitude, Longitude: a.Longitude}
+	}
+	for _, w := range f.Waypoints {
+		result[w.Name] = w
+	}
+	return result
+}
+
+// parseTasks parses the records in the related tasks section. Each task
+// line is followed by its options and observation zone lines.
+func parseTasks(records [][]string, points map[string]waypoint.Waypoint) ([]Task, error) {
+	var result []Task
+	var current *Task
+	// observation zones of each task, set once all its points are known
+	var zones []map[int]obsZone
+	for _, r := range records {
+		first := strings.TrimSpace(r[0])
+		switch {
+		case strings.EqualFold(first, "Options"):
+			if current == nil {
+				return nil, fmt.Errorf("task options with no task :: %v", r)
+			}
+			for _, f := range r[1:] {
+				kv := strings.SplitN(f, "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "TaskTime") {
+					d, err := parseDuration(kv[1])
+					if err != nil {
+						return nil, err
+					}
+					current.Kind, current.MinTime = task.AAT, d
+				}
+			}
+		case strings.HasPrefix(strings.ToLower(first), "obszone="):
+			if current == nil {
+				return nil, fmt.Errorf("observation zone with no task :: %v", r)
+			}
+			n, o, err := parseObsZone(r)
+			if err != nil {
+				return nil, err
+			}
+			if n > len(current.Turnpoints)+1 {
+				return nil, fmt.Errorf("observation zone %v out of task '%v'", n, current.Name)
+			}
+			zones[len(zones)-1][n] = o
+		case strings.Contains(first, "="):
+			// other task lines (Point, STARTS) are not supported
+			continue
+		default:
+			t, err := parseTask(r, points)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, t)
+			current = &result[len(result)-1]
+			zones = append(zones, map[int]obsZone{})
+		}
+	}
+	for i := range result {
+		if err := result[i].setZones(zones[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// setZones sets the given observation zones, keyed by task point index.
+func (t *Task) setZones(zones map[int]obsZone) error {
+	tps := append([]task.TaskPoint{t.Start}, t.Turnpoints...)
+	tps = append(tps, t.Finish)
+	for n, o := range zones {
+		z, err := o.zone(tps, n)
+		if err != nil {
+			return fmt.Errorf("invalid zone in task '%v' :: %v", t.Name, err)
+		}
+		switch {
+		case n == 0:
+			t.Start.Zone = z
+		case n < len(tps)-1:
+			t.Turnpoints[n-1].Zone = z
+		default:
+			t.Finish.Zone = z
+		}
+	}
+	return nil
+}
+
+// parseTask parses a task line, with the name, takeoff, task points and
+// landing. Zones are set from task.DefaultOptions.
+func parseTask(fields []string, points map[string]waypoint.Waypoint) (Task, error) {
+	if len(fields) < 5 {
+		return Task{}, fmt.Errorf("not enough points in task :: %v", fields)
+	}
+	t := Task{Name: fields[0], Takeoff: fields[1], Landing: fields[len(fields)-1]}
+	var tps []task.TaskPoint
+	for _, name := range fields[2 : len(fields)-1] {
+		w, ok := points[name]
+		if !ok {
+			return Task{}, fmt.Errorf("unknown point '%v' in task '%v'", name, t.Name)
+		}
+		tps = append(tps, task.TaskPoint{Waypoint: w, Zone: task.DefaultOptions.Turnpoint})
+	}
+	t.Start, t.Finish = tps[0], tps[len(tps)-1]
+	t.Start.Zone, t.Finish.Zone = task.DefaultOptions.Start, task.DefaultOptions.Finish
+	t.Turnpoints = tps[1 : len(tps)-1]
+	return t, nil
+}
+
+// writeTasks writes the given tasks, with their options and observation
+// zones, in the related tasks section format.
+func writeTasks(w io.Writer, tasks []Task) error {
+	for _, t := range tasks {
+		tps := append([]task.TaskPoint{t.Start}, t.Turnpoints...)
+		tps = append(tps, t.Finish)
+		names := []string{quote(t.Name), quote(t.Takeoff)}
+		for _, tp := range tps {
+			names = append(names, quote(tp.Waypoint.Name))
+		}
+		lines := []string{strings.Join(append(names, quote(t.Landing)), ",")}
+		if t.Kind == task.AAT {
+			d := int(t.MinTime.Seconds())
+			lines = append(lines, fmt.Sprintf("Options,TaskTime=%02d:%02d:%02d", d/3600, d%3600/60, d%60))
+		}
+		for i, tp := range tps {
+			style := symmetricStyle
+			if i == 0 {
+				style = nextStyle
+			} else if i == len(tps)-1 {
+				style = previousStyle
+			}
+			o, err := newObsZone(tp.Zone, style)
+			if err != nil {
+				return fmt.Errorf("invalid zone in task '%v' :: %v", t.Name, err)
+			}
+			lines = append(lines, o.String(i))
+		}
+		for _, l := range lines {
+			if _, err := io.WriteString(w, l+"\r\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the ObsZone line for task point n.
+func (o obsZone) String(n int) string {
+	s := fmt.Sprintf("ObsZone=%v,Style=%v,R1=%vm,A1=%v", n, o.style, o.r1, o.a1)
+	if o.r2 > 0 {
+		s += fmt.Sprintf(",R2=%vm,A2=%v", o.r2, o.a2)
+	}
+	if o.style == fixedStyle {
+		s += fmt.Sprintf(",A12=%v", o.a12)
+	}
+	if o.line {
+		s += ",Line=1"
+	}
+	return s
+}
+
+// quote returns s as a quoted CSV field.
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package cup
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/spatial"
+	"github.com/rochaporto/ezgliding/task"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+func parseTestFile(t *testing.T) File {
+	file, err := os.Open("t/test.cup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	f, err := Parse(file)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	return f
+}
+
+func TestParseTasks(t *testing.T) {
+	f := parseTestFile(t)
+	if len(f.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks got %v", len(f.Tasks))
+	}
+
+	racing := f.Tasks[0]
+	if racing.Name != "Jura 100" || racing.Takeoff != "Montricher" || racing.Landing != "Montricher" {
+		t.Errorf("unexpected task :: %+v", racing)
+	}
+	if racing.Kind != task.Racing || racing.Start.Waypoint.Name != "Montricher" ||
+		racing.Finish.Waypoint.Name != "Geneve" || len(racing.Turnpoints) != 2 {
+		t.Errorf("unexpected task points :: %+v", racing)
+	}
+	zones := []task.Zone{racing.Start.Zone, racing.Turnpoints[0].Zone, racing.Turnpoints[1].Zone, racing.Finish.Zone}
+	expected := []task.Zone{
+		{Type: task.Line, Radius: 5000},
+		{Type: task.Keyhole, Radius: 500, InnerRadius: 500},
+		{Type: task.Cylinder, Radius: 3000},
+		{Type: task.Line, Radius: 1000},
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected zones %+v got %+v", expected, zones)
+	}
+
+	aat := f.Tasks[1]
+	if aat.Kind != task.AAT || aat.MinTime != 150*time.Minute {
+		t.Errorf("expected aat of 2h30 got %v %v", aat.Kind, aat.MinTime)
+	}
+	sector := task.Zone{Type: task.Sector, Radius: 20000, Radial1: 30, Radial2: 150}
+	if !reflect.DeepEqual(aat.Turnpoints[0].Zone, sector) {
+		t.Errorf("expected zone %+v got %+v", sector, aat.Turnpoints[0].Zone)
+	}
+	if !reflect.DeepEqual(aat.Start.Zone, task.DefaultOptions.Start) ||
+		!reflect.DeepEqual(aat.Finish.Zone, task.DefaultOptions.Finish) {
+		t.Errorf("expected default zones got %+v %+v", aat.Start.Zone, aat.Finish.Zone)
+	}
+}
+
+func TestObsZone(t *testing.T) {
+	// east along the equator, then north
+	tps := []task.TaskPoint{
+		{Waypoint: waypoint.Waypoint{Latitude: 0, Longitude: 0}},
+		{Waypoint: waypoint.Waypoint{Latitude: 0, Longitude: 1}},
+		{Waypoint: waypoint.Waypoint{Latitude: 1, Longitude: 1}},
+	}
+	start := spatial.Bearing(1, 1, 0, 0)
+	tests := []struct {
+		line string
+		zone task.Zone
+	}{
+		{"ObsZone=0,Style=2,R1=5000m,A1=180,Line=1", task.Zone{Type: task.Line, Radius: 5000}},
+		{"ObsZone=1,Style=1,R1=10km,A1=45", task.Zone{Type: task.FAISector, Radius: 10000}},
+		{"ObsZone=1,Style=1,R1=10km,A1=30", task.Zone{Type: task.Sector, Radius: 10000, Radial1: 105, Radial2: 165}},
+		{"ObsZone=1,Style=2,R1=10km,A1=45", task.Zone{Type: task.Sector, Radius: 10000, Radial1: 315, Radial2: 45}},
+		{"ObsZone=1,Style=3,R1=10km,A1=45", task.Zone{Type: task.Sector, Radius: 10000, Radial1: 225, Radial2: 315}},
+		{"ObsZone=2,Style=4,R1=10km,A1=30",
+			task.Zone{Type: task.Sector, Radius: 10000, Radial1: start - 30, Radial2: start + 30}},
+		{"ObsZone=0,Style=1,R1=10km,A1=30", task.Zone{Type: task.Sector, Radius: 10000, Radial1: 240, Radial2: 300}},
+		{"ObsZone=2,Style=1,R1=10km,A1=30", task.Zone{Type: task.Sector, Radius: 10000, Radial1: 330, Radial2: 30}},
+		{"ObsZone=1,Style=0,R1=10km,A1=30,R2=2km,A2=30,A12=180",
+			task.Zone{Type: task.Sector, Radius: 10000, InnerRadius: 2000, Radial1: 150, Radial2: 210}},
+		{"ObsZone=1,Style=1,R1=500m,A1=180", task.Zone{Type: task.Cylinder, Radius: 500}},
+	}
+	for _, test := range tests {
+		n, o, err := parseObsZone(strings.Split(test.line, ","))
+		if err != nil {
+			t.Errorf("failed to parse '%v' :: %v", test.line, err)
+			continue
+		}
+		z, err := o.zone(tps, n)
+		if err != nil {
+			t.Errorf("failed to get zone for '%v' :: %v", test.line, err)
+			continue
+		}
+		radials := math.Abs(math.Remainder(z.Radial1-test.zone.Radial1, 360)) +
+			math.Abs(math.Remainder(z.Radial2-test.zone.Radial2, 360))
+		z.Radial1, z.Radial2 = test.zone.Radial1, test.zone.Radial2
+		if !reflect.DeepEqual(z, test.zone) || radials > 1e-6 {
+			t.Errorf("expected %+v for '%v' got %+v", test.zone, test.line, z)
+		}
+	}
+	for _, line := range []string{"ObsZone=2,Style=2,R1=10km,A1=30", "ObsZone=0,Style=3,R1=10km,A1=30",
+		"ObsZone=0,Style=4,R1=10km,A1=30", "ObsZone=1,Style=7,R1=10km,A1=30"} {
+		n, o, err := parseObsZone(strings.Split(line, ","))
+		if err != nil {
+			t.Errorf("failed to parse '%v' :: %v", line, err)
+			continue
+		}
+		if _, err := o.zone(tps, n); err == nil {
+			t.Errorf("expected error for '%v' but got success", line)
+		}
+	}
+}
+
+func TestParseTasksOrientation(t *testing.T) {
+	content := "\"Vaulion\",DVAUL,CH,4641.160N,00621.190E,1483m,7,,,,\r\n" +
+		"\"Yverdon\",YVBRI,CH,4646.500N,00638.500E,435m,1,,,,\r\n" +
+		"-----Related Tasks-----\r\n" +
+		"\"T\",\"Vaulion\",\"Vaulion\",\"Yverdon\",\"Vaulion\",\"Vaulion\"\r\n" +
+		"ObsZone=1,Style=3,R1=10km,A1=30\r\n"
+	f, err := Parse(strings.NewReader(content))
+	if err != nil || len(f.Tasks) != 1 {
+		t.Fatalf("failed to parse task :: %v", err)
+	}
+	// the sector at Yverdon points back to Vaulion, to the south west
+	z := f.Tasks[0].Turnpoints[0].Zone
+	previous := spatial.Bearing(46.775, 6.641666666666667, 46.686, 6.353166666666667)
+	if z.Type != task.Sector || math.Abs(z.Radial1-(previous-30)) > 1e-6 || math.Abs(z.Radial2-(previous+30)) > 1e-6 {
+		t.Errorf("expected sector around %v got %+v", previous, z)
+	}
+}
+
+func TestParseTasksInvalid(t *testing.T) {
+	header := "\"Montricher\",LSTR,CH,4635.383N,00624.117E,667m,4,,,,\r\n-----Related Tasks-----\r\n"
+	tests := []string{
+		`"T","Montricher","Montricher","Unknown","Montricher"`,
+		`"T","Montricher","Montricher","Montricher"`,
+		"ObsZone=0,Style=1,R1=500m,A1=180",
+		"Options,TaskTime=2h",
+		`"T","Montricher","Montricher","Montricher","Montricher"` + "\r\nObsZone=2,Style=1,R1=500m,A1=180",
+		`"T","Montricher","Montricher","Montricher","Montricher"` + "\r\nObsZone=0,Style=1,R1=500x,A1=180",
+		`"T","Montricher","Montricher","Montricher","Montricher"` + "\r\nObsZone=0,Style",
+		`"T","Montricher","Montricher","Montricher","Montricher"` + "\r\nObsZone=1,Style=2,R1=500m,A1=30",
+	}
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(header + test)); err == nil {
+			t.Errorf("expected error parsing '%v' but got success", test)
+		}
+	}
+}
+
+func TestWriteTasks(t *testing.T) {
+	f := parseTestFile(t)
+	var buf bytes.Buffer
+	if err := Write(&buf, f); err != nil {
+		t.Fatalf("failed to write :: %v", err)
+	}
+	result, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written content :: %v", err)
+	}
+	if len(result.Tasks) != len(f.Tasks) {
+		t.Fatalf("expected %v tasks got %v", len(f.Tasks), len(result.Tasks))
+	}
+	for i, r := range result.Tasks {
+		e := f.Tasks[i]
+		if r.Name != e.Name || r.Kind != e.Kind || r.MinTime != e.MinTime || len(r.Turnpoints) != len(e.Turnpoints) {
+			t.Errorf("expected task %+v got %+v", e, r)
+			continue
+		}
+		if !reflect.DeepEqual(r.Start.Zone, e.Start.Zone) || !reflect.DeepEqual(r.Finish.Zone, e.Finish.Zone) {
+			t.Errorf("expected zones %+v %+v got %+v %+v", e.Start.Zone, e.Finish.Zone, r.Start.Zone, r.Finish.Zone)
+		}
+		for j, tp := range r.Turnpoints {
+			if !reflect.DeepEqual(tp.Zone, e.Turnpoints[j].Zone) {
+				t.Errorf("expected zone %+v got %+v", e.Turnpoints[j].Zone, tp.Zone)
+			}
+		}
+	}
+}
+
+func TestWriteTasksPolygon(t *testing.T) {
+	f := parseTestFile(t)
+	f.Tasks[0].Turnpoints[0].Zone = task.Zone{Type: task.Polygon}
+	var buf bytes.Buffer
+	if err := Write(&buf, f); err == nil {
+		t.Errorf("expected error but got success")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package export writes flights in map formats: GeoJSON, KML and GPX.
+//
+// The track is always written, with the declared task turnpoints and the
+// thermals found by flight analysis as optional extra layers.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/rochaporto/ezgliding/analysis"
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+// Options holds the optional content of the exports.
+type Options struct {
+	// Task adds the declared task (flight.Task) turnpoints.
+	Task bool
+	// Thermals are added when given, usually from analysis.Analyze.
+	Thermals []analysis.Thermal
+	// Colored splits KML tracks in segments colored by altitude.
+	Colored bool
+	// Extrude draws KML tracks as curtains down to the ground.
+	Extrude bool
+}
+
+// altitudes returns the altitude (m) of each point, using the GNSS altitude
+// if any point has one and the pressure altitude otherwise.
+func altitudes(points []flight.Point) []int64 {
+	gnss := false
+	for _, p := range points {
+		if p.GNSSAltitude != 0 {
+			gnss = true
+			break
+		}
+	}
+	result := make([]int64, len(points))
+	for i, p := range points {
+		result[i] = p.PressureAltitude
+		if gnss {
+			result[i] = p.GNSSAltitude
+		}
+	}
+	return result
+}
+
+// taskPoints returns the declared task points with a position, from start to
+// finish.
+func taskPoints(t flight.Task) []flight.Point {
+	var result []flight.Point
+	for _, p := range append(append([]flight.Point{t.Start}, t.Turnpoints...), t.Finish) {
+		if p.Latitude != 0 || p.Longitude != 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// title returns a name for the flight, from the pilot and date.
+func title(f flight.Flight) string {
+	name := f.Header.Pilot
+	if name == "" {
+		name = "flight"
+	}
+	if !f.Header.Date.IsZero() {
+		name += " " + f.Header.Date.Format("2006-01-02")
+	}
+	return name
+}
+
+// thermalName returns a name for the i-th thermal.
+func thermalName(i int, t analysis.Thermal) string {
+	return fmt.Sprintf("Thermal %v (%+.0fm, %.1fm/s)", i+1, t.Gain, t.AvgClimb)
+}
+
+// xmlWriter writes xml documents, keeping the first error found.
+type xmlWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	xw := &xmlWriter{w: bufio.NewWriter(w)}
+	xw.printf("%v", xml.Header)
+	return xw
+}
+
+// printf writes the given text, with any string arguments escaped.
+func (xw *xmlWriter) printf(format string, a ...interface{}) {
+	if xw.err != nil {
+		return
+	}
+	for i, v := range a {
+		if s, ok := v.(string); ok {
+			var b bytes.Buffer
+			xml.EscapeText(&b, []byte(s))
+			a[i] = b.String()
+		}
+	}
+	_, xw.err = fmt.Fprintf(xw.w, format, a...)
+}
+
+// flush flushes the written content and returns the first error found.
+func (xw *xmlWriter) flush() error {
+	if xw.err != nil {
+		return xw.err
+	}
+	return xw.w.Flush()
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/analysis"
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+// testFlight returns a flight with 10 points climbing from 400 m to 1300 m,
+// and a declared task with two turnpoints.
+func testFlight() flight.Flight {
+	f := flight.NewFlight()
+	f.Header.Pilot = "Jean <Dupont>"
+	f.Header.Date = time.Date(2015, 6, 20, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2015, 6, 20, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		p := flight.NewPoint()
+		p.Time = start.Add(time.Duration(i) * time.Minute)
+		p.Latitude, p.Longitude = 46.2+float64(i)*0.01, 6.1+float64(i)*0.005
+		p.PressureAltitude, p.GNSSAltitude = int64(390+i*100), int64(400+i*100)
+		f.Points = append(f.Points, p)
+	}
+	f.Task.Description = "300km & back"
+	for i, name := range []string{"START", "TP1", "TP2", "FINISH"} {
+		p := flight.NewPoint()
+		p.Latitude, p.Longitude, p.Description = 46+float64(i)*0.5, 6+float64(i%2)*0.5, name
+		switch i {
+		case 0:
+			f.Task.Start = p
+		case 3:
+			f.Task.Finish = p
+		default:
+			f.Task.Turnpoints = append(f.Task.Turnpoints, p)
+		}
+	}
+	return f
+}
+
+var testThermals = []analysis.Thermal{
+	{Start: 2, End: 5, Entry: time.Date(2015, 6, 20, 10, 2, 0, 0, time.UTC),
+		Exit: time.Date(2015, 6, 20, 10, 5, 0, 0, time.UTC), Gain: 300, AvgClimb: 1.7},
+	// out of the track, ignored
+	{Start: 20, End: 25},
+}
+
+func TestAltitudes(t *testing.T) {
+	f := testFlight()
+	if alt := altitudes(f.Points); alt[0] != 400 || alt[9] != 1300 {
+		t.Errorf("expected gnss altitudes got %v", alt)
+	}
+	for i := range f.Points {
+		f.Points[i].GNSSAltitude = 0
+	}
+	if alt := altitudes(f.Points); alt[0] != 390 || alt[9] != 1290 {
+		t.Errorf("expected pressure altitudes got %v", alt)
+	}
+}
+
+func TestTaskPoints(t *testing.T) {
+	f := testFlight()
+	if points := taskPoints(f.Task); len(points) != 4 || points[3].Description != "FINISH" {
+		t.Errorf("expected 4 task points got %+v", points)
+	}
+	if points := taskPoints(flight.Task{}); len(points) != 0 {
+		t.Errorf("expected no task points got %+v", points)
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	writers := map[string]func(w io.Writer, f flight.Flight, opts Options) error{
+		"geojson": GeoJSON, "kml": KML, "gpx": GPX,
+	}
+	for name, write := range writers {
+		if err := write(ioutil.Discard, flight.NewFlight(), Options{}); err == nil {
+			t.Errorf("%v :: expected error got none", name)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+// GeoJSON writes the flight to w as a GeoJSON feature collection.
+//
+// The track is a LineString with altitude as the third coordinate, and the
+// point times in the "Times" property. Task turnpoints are Point features
+// plus a LineString for the course, and thermals are Point features at their
+// entry. Features have a "Layer" property set to track, task or thermal.
+func GeoJSON(w io.Writer, f flight.Flight, opts Options) error {
+	if len(f.Points) == 0 {
+		return errors.New("no points to export")
+	}
+	collection := geojson.NewFeatureCollection()
+	alt := altitudes(f.Points)
+	coordinates := make([][]float64, len(f.Points))
+	times := make([]string, len(f.Points))
+	for i, p := range f.Points {
+		coordinates[i] = []float64{p.Longitude, p.Latitude, float64(alt[i])}
+		times[i] = p.Time.Format(time.RFC3339)
+	}
+	track := geojson.NewLineStringFeature(coordinates)
+	track.SetProperty("Layer", "track")
+	track.SetProperty("Name", title(f))
+	track.SetProperty("Pilot", f.Header.Pilot)
+	track.SetProperty("GliderType", f.Header.GliderType)
+	track.SetProperty("GliderID", f.Header.GliderID)
+	track.SetProperty("Times", times)
+	collection.AddFeature(track)
+
+	if opts.Task {
+		var course [][]float64
+		for _, p := range taskPoints(f.Task) {
+			c := []float64{p.Longitude, p.Latitude}
+			course = append(course, c)
+			g := geojson.NewPointFeature(c)
+			g.SetProperty("Layer", "task")
+			g.SetProperty("Name", p.Description)
+			collection.AddFeature(g)
+		}
+		if len(course) > 1 {
+			g := geojson.NewLineStringFeature(course)
+			g.SetProperty("Layer", "task")
+			g.SetProperty("Name", f.Task.Description)
+			collection.AddFeature(g)
+		}
+	}
+
+	for i, t := range opts.Thermals {
+		if t.Start < 0 || t.Start >= len(f.Points) {
+			continue
+		}
+		p := f.Points[t.Start]
+		g := geojson.NewPointFeature([]float64{p.Longitude, p.Latitude, float64(alt[t.Start])})
+		g.SetProperty("Layer", "thermal")
+		g.SetProperty("Name", thermalName(i, t))
+		g.SetProperty("Entry", t.Entry.Format(time.RFC3339))
+		g.SetProperty("Exit", t.Exit.Format(time.RFC3339))
+		g.SetProperty("Gain", t.Gain)
+		g.SetProperty("AvgClimb", t.AvgClimb)
+		g.SetProperty("PeakClimb", t.PeakClimb)
+		collection.AddFeature(g)
+	}
+	return json.NewEncoder(w).Encode(collection)
+}
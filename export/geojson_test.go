@@ -0,0 +1,77 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/paulmach/go.geojson"
+)
+
+func TestGeoJSON(t *testing.T) {
+	tests := []struct {
+		t    string
+		opts Options
+		// expected number of features per layer
+		layers map[string]int
+	}{
+		{"track only", Options{}, map[string]int{"track": 1}},
+		{"with task", Options{Task: true}, map[string]int{"track": 1, "task": 5}},
+		{"with thermals", Options{Thermals: testThermals}, map[string]int{"track": 1, "thermal": 1}},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := GeoJSON(&b, testFlight(), test.opts); err != nil {
+			t.Errorf("%v :: failed to export :: %v", test.t, err)
+			continue
+		}
+		collection, err := geojson.UnmarshalFeatureCollection(b.Bytes())
+		if err != nil {
+			t.Errorf("%v :: failed to parse result :: %v", test.t, err)
+			continue
+		}
+		layers := map[string]int{}
+		for _, f := range collection.Features {
+			layers[f.PropertyMustString("Layer")]++
+		}
+		if len(layers) != len(test.layers) {
+			t.Errorf("%v :: expected layers %v got %v", test.t, test.layers, layers)
+		}
+		for k, v := range test.layers {
+			if layers[k] != v {
+				t.Errorf("%v :: expected layers %v got %v", test.t, test.layers, layers)
+			}
+		}
+		track := collection.Features[0]
+		if !track.Geometry.IsLineString() || len(track.Geometry.LineString) != 10 {
+			t.Errorf("%v :: expected track with 10 points got %+v", test.t, track.Geometry)
+			continue
+		}
+		if c := track.Geometry.LineString[9]; math.Abs(c[0]-6.145) > 1e-9 || math.Abs(c[1]-46.29) > 1e-9 || c[2] != 1300 {
+			t.Errorf("%v :: expected last point 6.145,46.29,1300 got %v", test.t, c)
+		}
+		if times, ok := track.Properties["Times"].([]interface{}); !ok || len(times) != 10 ||
+			times[1] != "2015-06-20T10:01:00Z" {
+			t.Errorf("%v :: unexpected times %v", test.t, track.Properties["Times"])
+		}
+	}
+}
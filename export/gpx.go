@@ -0,0 +1,70 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+// GPX writes the flight to w in the GPX 1.1 format.
+//
+// The track has the elevation and time of each point. Task turnpoints are
+// written as a route and thermals as waypoints, timed at their entry.
+func GPX(w io.Writer, f flight.Flight, opts Options) error {
+	if len(f.Points) == 0 {
+		return errors.New("no points to export")
+	}
+	xw := newXMLWriter(w)
+	xw.printf("<gpx version=\"1.1\" creator=\"ezgliding\" xmlns=\"http://www.topografix.com/GPX/1/1\">\n")
+	xw.printf("<metadata><name>%v</name><time>%v</time></metadata>\n",
+		title(f), f.Points[0].Time.UTC().Format(time.RFC3339))
+
+	alt := altitudes(f.Points)
+	for i, t := range opts.Thermals {
+		if t.Start < 0 || t.Start >= len(f.Points) {
+			continue
+		}
+		p := f.Points[t.Start]
+		xw.printf("<wpt lat=\"%.6f\" lon=\"%.6f\"><ele>%v</ele><time>%v</time><name>%v</name></wpt>\n",
+			p.Latitude, p.Longitude, alt[t.Start], t.Entry.UTC().Format(time.RFC3339), thermalName(i, t))
+	}
+	if opts.Task {
+		if points := taskPoints(f.Task); len(points) > 0 {
+			xw.printf("<rte><name>%v</name>\n", f.Task.Description)
+			for _, p := range points {
+				xw.printf("<rtept lat=\"%.6f\" lon=\"%.6f\"><name>%v</name></rtept>\n",
+					p.Latitude, p.Longitude, p.Description)
+			}
+			xw.printf("</rte>\n")
+		}
+	}
+
+	xw.printf("<trk><name>%v</name><trkseg>\n", title(f))
+	for i, p := range f.Points {
+		xw.printf("<trkpt lat=\"%.6f\" lon=\"%.6f\"><ele>%v</ele><time>%v</time></trkpt>\n",
+			p.Latitude, p.Longitude, alt[i], p.Time.UTC().Format(time.RFC3339))
+	}
+	xw.printf("</trkseg></trk>\n</gpx>\n")
+	return xw.flush()
+}
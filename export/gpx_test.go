@@ -0,0 +1,88 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+type gpxPoint struct {
+	Lat       float64 `xml:"lat,attr"`
+	Lon       float64 `xml:"lon,attr"`
+	Elevation float64 `xml:"ele"`
+	Time      string  `xml:"time"`
+	Name      string  `xml:"name"`
+}
+
+type gpxDocument struct {
+	Name      string     `xml:"metadata>name"`
+	Time      string     `xml:"metadata>time"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Route     []gpxPoint `xml:"rte>rtept"`
+	Track     []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+func TestGPX(t *testing.T) {
+	tests := []struct {
+		t         string
+		opts      Options
+		waypoints int
+		route     int
+	}{
+		{"track only", Options{}, 0, 0},
+		{"with task and thermals", Options{Task: true, Thermals: testThermals}, 1, 4},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := GPX(&b, testFlight(), test.opts); err != nil {
+			t.Errorf("%v :: failed to export :: %v", test.t, err)
+			continue
+		}
+		var doc gpxDocument
+		if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+			t.Errorf("%v :: failed to parse result :: %v", test.t, err)
+			continue
+		}
+		if doc.Name != "Jean <Dupont> 2015-06-20" || doc.Time != "2015-06-20T10:00:00Z" {
+			t.Errorf("%v :: unexpected metadata %v %v", test.t, doc.Name, doc.Time)
+		}
+		if len(doc.Track) != 10 {
+			t.Errorf("%v :: expected 10 track points got %v", test.t, len(doc.Track))
+			continue
+		}
+		last := doc.Track[9]
+		if last.Lat != 46.29 || last.Lon != 6.145 || last.Elevation != 1300 || last.Time != "2015-06-20T10:09:00Z" {
+			t.Errorf("%v :: unexpected last track point %+v", test.t, last)
+		}
+		if len(doc.Waypoints) != test.waypoints || len(doc.Route) != test.route {
+			t.Errorf("%v :: expected %v waypoints %v route points got %+v %+v", test.t,
+				test.waypoints, test.route, doc.Waypoints, doc.Route)
+			continue
+		}
+		if test.waypoints > 0 && (doc.Waypoints[0].Time != "2015-06-20T10:02:00Z" || doc.Waypoints[0].Elevation != 600) {
+			t.Errorf("%v :: unexpected thermal waypoint %+v", test.t, doc.Waypoints[0])
+		}
+		if test.route > 0 && doc.Route[3].Name != "FINISH" {
+			t.Errorf("%v :: unexpected route %+v", test.t, doc.Route)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+// altitudeColors are the KML colors (aabbggrr) of the altitude bands used in
+// colored tracks, from the lowest to the highest.
+var altitudeColors = []string{
+	"ffff0000", "ffff8000", "ffffff00", "ff00ff00", "ff00ffff", "ff0080ff", "ff0000ff", "ff8000ff",
+}
+
+// trackColor is the KML color of tracks not colored by altitude.
+const trackColor = "ff00ffff"
+
+// KML writes the flight to w in the KML format, for Google Earth.
+//
+// The track uses absolute altitudes, split in segments colored by altitude
+// when Options.Colored is set and extruded to the ground when
+// Options.Extrude is set. Task turnpoints and thermals go in separate
+// folders.
+func KML(w io.Writer, f flight.Flight, opts Options) error {
+	if len(f.Points) == 0 {
+		return errors.New("no points to export")
+	}
+	xw := newXMLWriter(w)
+	xw.printf("<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n<Document>\n<name>%v</name>\n", title(f))
+	xw.printf("<Style id=\"track\"><LineStyle><color>%v</color><width>2</width></LineStyle>"+
+		"<PolyStyle><color>7f%v</color></PolyStyle></Style>\n", trackColor, trackColor[2:])
+	for i, c := range altitudeColors {
+		xw.printf("<Style id=\"alt%v\"><LineStyle><color>%v</color><width>2</width></LineStyle>"+
+			"<PolyStyle><color>7f%v</color></PolyStyle></Style>\n", i, c, c[2:])
+	}
+	xw.printf("<Style id=\"task\"><LineStyle><color>ffffffff</color><width>1</width></LineStyle></Style>\n")
+
+	alt := altitudes(f.Points)
+	xw.printf("<Folder><name>Track</name>\n")
+	for _, s := range segments(alt, opts.Colored) {
+		style := "track"
+		if opts.Colored {
+			style = fmt.Sprintf("alt%v", s.band)
+		}
+		extrude := 0
+		if opts.Extrude {
+			extrude = 1
+		}
+		xw.printf("<Placemark><styleUrl>#%v</styleUrl><TimeSpan><begin>%v</begin><end>%v</end></TimeSpan>"+
+			"<LineString><extrude>%v</extrude><altitudeMode>absolute</altitudeMode><coordinates>\n",
+			style, f.Points[s.start].Time.UTC().Format("2006-01-02T15:04:05Z"),
+			f.Points[s.end].Time.UTC().Format("2006-01-02T15:04:05Z"), extrude)
+		for i := s.start; i <= s.end; i++ {
+			p := f.Points[i]
+			xw.printf("%.6f,%.6f,%v\n", p.Longitude, p.Latitude, alt[i])
+		}
+		xw.printf("</coordinates></LineString></Placemark>\n")
+	}
+	xw.printf("</Folder>\n")
+
+	if opts.Task {
+		if points := taskPoints(f.Task); len(points) > 0 {
+			xw.printf("<Folder><name>Task</name>\n")
+			for _, p := range points {
+				xw.printf("<Placemark><name>%v</name><Point><coordinates>%.6f,%.6f</coordinates></Point></Placemark>\n",
+					p.Description, p.Longitude, p.Latitude)
+			}
+			xw.printf("<Placemark><name>%v</name><styleUrl>#task</styleUrl><LineString>"+
+				"<tessellate>1</tessellate><altitudeMode>clampToGround</altitudeMode><coordinates>\n",
+				f.Task.Description)
+			for _, p := range points {
+				xw.printf("%.6f,%.6f\n", p.Longitude, p.Latitude)
+			}
+			xw.printf("</coordinates></LineString></Placemark>\n</Folder>\n")
+		}
+	}
+
+	if len(opts.Thermals) > 0 {
+		xw.printf("<Folder><name>Thermals</name>\n")
+		for i, t := range opts.Thermals {
+			if t.Start < 0 || t.Start >= len(f.Points) {
+				continue
+			}
+			p := f.Points[t.Start]
+			xw.printf("<Placemark><name>%v</name><Point><altitudeMode>absolute</altitudeMode>"+
+				"<coordinates>%.6f,%.6f,%v</coordinates></Point></Placemark>\n",
+				thermalName(i, t), p.Longitude, p.Latitude, alt[t.Start])
+		}
+		xw.printf("</Folder>\n")
+	}
+	xw.printf("</Document>\n</kml>\n")
+	return xw.flush()
+}
+
+// segment is a part of the track in a single altitude band, with the indices
+// of its first and last points.
+type segment struct {
+	start int
+	end   int
+	band  int
+}
+
+// segments splits the track in segments of the same altitude band, sharing
+// their boundary points. With colored unset the whole track is returned.
+func segments(alt []int64, colored bool) []segment {
+	if !colored {
+		return []segment{{start: 0, end: len(alt) - 1}}
+	}
+	min, max := alt[0], alt[0]
+	for _, a := range alt {
+		if a < min {
+			min = a
+		}
+		if a > max {
+			max = a
+		}
+	}
+	band := func(a int64) int {
+		if max == min {
+			return 0
+		}
+		b := int(float64(a-min) / float64(max-min) * float64(len(altitudeColors)))
+		if b >= len(altitudeColors) {
+			b = len(altitudeColors) - 1
+		}
+		return b
+	}
+	result := []segment{{start: 0, end: 0, band: band(alt[0])}}
+	for i := 1; i < len(alt); i++ {
+		current := &result[len(result)-1]
+		current.end = i
+		if b := band(alt[i]); b != current.band && i < len(alt)-1 {
+			result = append(result, segment{start: i, end: i, band: b})
+		}
+	}
+	return result
+}
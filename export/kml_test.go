@@ -0,0 +1,152 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Style       string `xml:"styleUrl"`
+	Extrude     int    `xml:"LineString>extrude"`
+	Line        string `xml:"LineString>coordinates"`
+	Point       string `xml:"Point>coordinates"`
+	TimeBegin   string `xml:"TimeSpan>begin"`
+	TimeEnd     string `xml:"TimeSpan>end"`
+	AltitudeMod string `xml:"LineString>altitudeMode"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"Document>name"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+func parseKML(t *testing.T, opts Options) kmlDocument {
+	var b bytes.Buffer
+	if err := KML(&b, testFlight(), opts); err != nil {
+		t.Fatalf("failed to export :: %v", err)
+	}
+	var doc kmlDocument
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse result :: %v\n%v", err, b.String())
+	}
+	return doc
+}
+
+func TestKML(t *testing.T) {
+	doc := parseKML(t, Options{})
+	if doc.Name != "Jean <Dupont> 2015-06-20" {
+		t.Errorf("expected escaped name got %v", doc.Name)
+	}
+	if len(doc.Folders) != 1 || len(doc.Folders[0].Placemarks) != 1 {
+		t.Fatalf("expected a single track placemark got %+v", doc.Folders)
+	}
+	p := doc.Folders[0].Placemarks[0]
+	coordinates := strings.Fields(p.Line)
+	if len(coordinates) != 10 || coordinates[9] != "6.145000,46.290000,1300" {
+		t.Errorf("expected 10 coordinates ending at 6.145000,46.290000,1300 got %v", coordinates)
+	}
+	if p.Style != "#track" || p.Extrude != 0 || p.AltitudeMod != "absolute" ||
+		p.TimeBegin != "2015-06-20T10:00:00Z" || p.TimeEnd != "2015-06-20T10:09:00Z" {
+		t.Errorf("unexpected track placemark %+v", p)
+	}
+}
+
+func TestKMLColoredExtruded(t *testing.T) {
+	doc := parseKML(t, Options{Colored: true, Extrude: true})
+	placemarks := doc.Folders[0].Placemarks
+	// 900 m in 8 bands, with 100 m between points
+	if len(placemarks) != 8 {
+		t.Fatalf("expected 8 segments got %v", len(placemarks))
+	}
+	last := ""
+	for i, p := range placemarks {
+		coordinates := strings.Fields(p.Line)
+		if p.Style == last || p.Extrude != 1 || len(coordinates) < 2 {
+			t.Errorf("%v :: unexpected segment %+v", i, p)
+		}
+		if i > 0 {
+			previous := strings.Fields(placemarks[i-1].Line)
+			if previous[len(previous)-1] != coordinates[0] {
+				t.Errorf("%v :: segment not connected to the previous one", i)
+			}
+		}
+		last = p.Style
+	}
+	if placemarks[0].Style != "#alt0" || placemarks[7].Style != "#alt7" {
+		t.Errorf("expected styles from #alt0 to #alt7 got %v %v", placemarks[0].Style, placemarks[7].Style)
+	}
+}
+
+func TestKMLLayers(t *testing.T) {
+	doc := parseKML(t, Options{Task: true, Thermals: testThermals})
+	if len(doc.Folders) != 3 || doc.Folders[1].Name != "Task" || doc.Folders[2].Name != "Thermals" {
+		t.Fatalf("expected track, task and thermal folders got %+v", doc.Folders)
+	}
+	task := doc.Folders[1].Placemarks
+	if len(task) != 5 || task[1].Name != "TP1" || task[4].Name != "300km & back" ||
+		len(strings.Fields(task[4].Line)) != 4 {
+		t.Errorf("unexpected task placemarks %+v", task)
+	}
+	thermals := doc.Folders[2].Placemarks
+	if len(thermals) != 1 || thermals[0].Point != "6.110000,46.220000,600" ||
+		thermals[0].Name != "Thermal 1 (+300m, 1.7m/s)" {
+		t.Errorf("unexpected thermal placemarks %+v", thermals)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		alt     []int64
+		colored bool
+		bands   []int
+	}{
+		{[]int64{100, 200, 300}, false, []int{0}},
+		{[]int64{100, 100, 100}, true, []int{0}},
+		{[]int64{100, 900, 100}, true, []int{0, 7}},
+		{[]int64{100, 100, 900, 900, 100, 100}, true, []int{0, 7, 0}},
+		// no single point segment at the end
+		{[]int64{100, 100, 900, 900, 100}, true, []int{0, 7}},
+	}
+	for _, test := range tests {
+		result := segments(test.alt, test.colored)
+		if len(result) != len(test.bands) {
+			t.Errorf("%v :: expected bands %v got %+v", test.alt, test.bands, result)
+			continue
+		}
+		for i, s := range result {
+			if s.band != test.bands[i] || (i > 0 && s.start != result[i-1].end) {
+				t.Errorf("%v :: expected bands %v got %+v", test.alt, test.bands, result)
+			}
+		}
+		if result[0].start != 0 || result[len(result)-1].end != len(test.alt)-1 {
+			t.Errorf("%v :: segments do not cover the track :: %+v", test.alt, result)
+		}
+	}
+}
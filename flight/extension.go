@@ -0,0 +1,138 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extension describes one of the standard I and J record extensions, as
+// defined in Appendix A7 (Three Letter Codes) of the IGC spec.
+//
+// Raw values are multiplied by Scale to get a value in Unit. When Fraction is
+// set the raw digits are the decimal places of the value (0.DDD) instead.
+// Min and Max give the range allowed for raw values, and are not checked when
+// equal.
+type Extension struct {
+	Code        string
+	Description string
+	Unit        string
+	Scale       float64
+	Fraction    bool
+	Min         float64
+	Max         float64
+}
+
+// kmh is the scale from kilometres per hour to metres per second.
+const kmh = 1 / 3.6
+
+// Extensions holds the list of known extensions, keyed by their three
+// letter code.
+var Extensions = map[string]Extension{
+	"ENL": Extension{"ENL", "Environmental Noise Level", "", 1, false, 0, 999},
+	"MOP": Extension{"MOP", "Means of Propulsion", "", 1, false, 0, 999},
+	"FXA": Extension{"FXA", "Fix accuracy", "m", 1, false, 0, 9999},
+	"VXA": Extension{"VXA", "Vertical fix accuracy", "m", 1, false, 0, 999},
+	"SIU": Extension{"SIU", "Satellites in use", "", 1, false, 0, 99},
+	"RAI": Extension{"RAI", "RAIM", "", 1, false, 0, 0},
+	"TAS": Extension{"TAS", "Airspeed true", "m/s", kmh, false, 0, 999},
+	"IAS": Extension{"IAS", "Airspeed", "m/s", kmh, false, 0, 999},
+	"GSP": Extension{"GSP", "Groundspeed", "m/s", kmh, false, 0, 999},
+	"WSP": Extension{"WSP", "Wind speed", "m/s", kmh, false, 0, 999},
+	"HDT": Extension{"HDT", "Heading true", "deg", 1, false, 0, 359},
+	"HDM": Extension{"HDM", "Heading magnetic", "deg", 1, false, 0, 359},
+	"TRT": Extension{"TRT", "Track true", "deg", 1, false, 0, 359},
+	"TRM": Extension{"TRM", "Track magnetic", "deg", 1, false, 0, 359},
+	"CCO": Extension{"CCO", "Compass course", "deg", 1, false, 0, 359},
+	"WDI": Extension{"WDI", "Wind direction", "deg", 1, false, 0, 359},
+	"OAT": Extension{"OAT", "Outside air temperature", "C", 1, false, 0, 0},
+	"VAR": Extension{"VAR", "Uncompensated variometer", "m/s", 0.1, false, 0, 0},
+	"VAT": Extension{"VAT", "Compensated variometer", "m/s", 0.1, false, 0, 0},
+	"TEN": Extension{"TEN", "Total energy altitude", "m", 1, false, 0, 0},
+	"DAE": Extension{"DAE", "Displacement east", "m", 1, false, 0, 0},
+	"DAN": Extension{"DAN", "Displacement north", "m", 1, false, 0, 0},
+	"TDS": Extension{"TDS", "Decimal seconds of UTC time", "s", 1, true, 0, 0},
+	"LAD": Extension{"LAD", "Last places of latitude decimal minutes", "deg", 0.001 / 60, true, 0, 0},
+	"LOD": Extension{"LOD", "Last places of longitude decimal minutes", "deg", 0.001 / 60, true, 0, 0},
+}
+
+// ExtensionValue is a decoded extension value.
+type ExtensionValue struct {
+	Code  string
+	Raw   string
+	Value float64
+	Unit  string
+	// OutOfRange is set when the raw value is not in the range allowed
+	// by the IGC spec.
+	OutOfRange bool
+}
+
+// DecodeExtension converts the raw extension value given to a typed value,
+// following the definitions in Extensions.
+// Unknown codes and non numeric values return an error.
+func DecodeExtension(code string, raw string) (ExtensionValue, error) {
+	ext, ok := Extensions[code]
+	if !ok {
+		return ExtensionValue{}, fmt.Errorf("unknown extension :: %v", code)
+	}
+	value := strings.TrimSpace(raw)
+	// only plain (optionally signed) digits are valid values
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return ExtensionValue{}, fmt.Errorf("invalid %v value :: %v", code, raw)
+	}
+	v := float64(n)
+	result := ExtensionValue{Code: code, Raw: raw, Unit: ext.Unit}
+	if ext.Min != ext.Max && (v < ext.Min || v > ext.Max) {
+		result.OutOfRange = true
+	}
+	if ext.Fraction {
+		if v < 0 {
+			result.OutOfRange = true
+		}
+		v = v / math.Pow(10, float64(len(value)))
+	}
+	result.Value = v * ext.Scale
+	return result, nil
+}
+
+// Extension returns the decoded value of the given extension in the point
+// (B record) data.
+func (p Point) Extension(code string) (ExtensionValue, error) {
+	raw, ok := p.IData[code]
+	if !ok {
+		return ExtensionValue{}, fmt.Errorf("missing extension :: %v", code)
+	}
+	return DecodeExtension(code, raw)
+}
+
+// KExtension returns the decoded value of the given extension in the K
+// record at time t.
+func (f Flight) KExtension(t time.Time, code string) (ExtensionValue, error) {
+	raw, ok := f.K[t][code]
+	if !ok {
+		return ExtensionValue{}, fmt.Errorf("missing extension :: %v at %v", code, t)
+	}
+	return DecodeExtension(code, raw)
+}
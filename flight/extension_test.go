@@ -0,0 +1,112 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type DecodeExtensionTest struct {
+	t    string
+	code string
+	raw  string
+	r    ExtensionValue
+	e    bool
+}
+
+var decodeExtensionTests = []DecodeExtensionTest{
+	{"engine noise level", "ENL", "020",
+		ExtensionValue{Code: "ENL", Raw: "020", Value: 20}, false},
+	{"engine noise level out of range", "ENL", "-01",
+		ExtensionValue{Code: "ENL", Raw: "-01", Value: -1, OutOfRange: true}, false},
+	{"means of propulsion", "MOP", "999",
+		ExtensionValue{Code: "MOP", Raw: "999", Value: 999}, false},
+	{"true airspeed in m/s", "TAS", "108",
+		ExtensionValue{Code: "TAS", Raw: "108", Value: 30, Unit: "m/s"}, false},
+	{"heading", "HDT", "090",
+		ExtensionValue{Code: "HDT", Raw: "090", Value: 90, Unit: "deg"}, false},
+	{"heading out of range", "HDT", "360",
+		ExtensionValue{Code: "HDT", Raw: "360", Value: 360, Unit: "deg", OutOfRange: true}, false},
+	{"fix accuracy", "FXA", "035",
+		ExtensionValue{Code: "FXA", Raw: "035", Value: 35, Unit: "m"}, false},
+	{"negative vario tenths", "VAT", "-12",
+		ExtensionValue{Code: "VAT", Raw: "-12", Value: -1.2, Unit: "m/s"}, false},
+	{"negative temperature", "OAT", "-05",
+		ExtensionValue{Code: "OAT", Raw: "-05", Value: -5, Unit: "C"}, false},
+	{"decimal seconds", "TDS", "25",
+		ExtensionValue{Code: "TDS", Raw: "25", Value: 0.25, Unit: "s"}, false},
+	{"latitude decimal places", "LAD", "5",
+		ExtensionValue{Code: "LAD", Raw: "5", Value: 0.5 * 0.001 / 60, Unit: "deg"}, false},
+	{"unknown code", "ZZZ", "001", ExtensionValue{}, true},
+	{"not a number", "ENL", "0a1", ExtensionValue{}, true},
+	{"decimal point not allowed", "VAT", "1.2", ExtensionValue{}, true},
+	{"exponent not allowed", "ENL", "1e2", ExtensionValue{}, true},
+	{"nan not allowed", "ENL", "nan", ExtensionValue{}, true},
+	{"infinity not allowed", "OAT", "-Infinity", ExtensionValue{}, true},
+	{"inf not allowed", "ENL", "inf", ExtensionValue{}, true},
+	{"hex float not allowed", "ENL", "0x1p3", ExtensionValue{}, true},
+	{"empty value", "ENL", " ", ExtensionValue{}, true},
+}
+
+func TestDecodeExtension(t *testing.T) {
+	for _, test := range decodeExtensionTests {
+		result, err := DecodeExtension(test.code, test.raw)
+		if err != nil && test.e {
+			continue
+		} else if err != nil || test.e {
+			t.Errorf("%v failed :: %v", test.t, err)
+			continue
+		}
+		if math.Abs(result.Value-test.r.Value) > 1e-9 {
+			t.Errorf("%v failed :: expected value %v got %v", test.t, test.r.Value, result.Value)
+		}
+		result.Value = test.r.Value
+		if result != test.r {
+			t.Errorf("%v failed :: expected %+v got %+v", test.t, test.r, result)
+		}
+	}
+}
+
+func TestPointExtension(t *testing.T) {
+	p := NewPoint()
+	p.IData["ENL"] = "250"
+	v, err := p.Extension("ENL")
+	if err != nil || v.Value != 250 {
+		t.Errorf("expected ENL 250 got %v :: %v", v.Value, err)
+	}
+	if _, err = p.Extension("MOP"); err == nil {
+		t.Errorf("expected missing extension to fail")
+	}
+}
+
+func TestKExtension(t *testing.T) {
+	f := NewFlight()
+	k := time.Date(2015, time.May, 1, 12, 0, 0, 0, time.UTC)
+	f.K[k] = map[string]string{"WSP": "036", "WDI": "270"}
+	v, err := f.KExtension(k, "WSP")
+	if err != nil || math.Abs(v.Value-10) > 1e-9 {
+		t.Errorf("expected wind speed 10 m/s got %v :: %v", v.Value, err)
+	}
+	if _, err = f.KExtension(k.Add(time.Second), "WSP"); err == nil {
+		t.Errorf("expected missing K record to fail")
+	}
+}
@@ -50,7 +50,9 @@ type Flight struct {
 	Logbook       []LogEntry
 	Task          Task
 	DGPSStationID string
-	Signature     string
+	// Signature holds the content of the G records, one line per record,
+	// check ValidateIGC.
+	Signature string
 	// Sources is a map keyed on the plugin ID containing flight info
 	// directly taken from the online site (no flight log parsing). There can
 	// be multiple sources for the same flight.
@@ -74,6 +76,7 @@ type Header struct {
 	UniqueID         string
 	AdditionalData   string
 	Date             time.Time
+	FlightNumber     int
 	FixAccuracy      int64
 	Pilot            string
 	Crew             string
@@ -88,6 +91,10 @@ type Header struct {
 	CompetitionID    string
 	CompetitionClass string
 	Timezone         time.Location
+	// Extra holds H records not known to the parser, keyed by their source
+	// letter (F, O or P) and three letter code, like FSIT (only set when
+	// parsing in lenient mode).
+	Extra map[string]string
 }
 
 // Point represents a gps read (single point in the flight track).
@@ -163,6 +170,7 @@ var Manufacturers = map[string]Manufacturer{
 	"TRI": Manufacturer{'T', "TRI", "Triadis Engineering GmbH"},
 	"LXV": Manufacturer{'V', "LXV", "LXNAV d.o.o."},
 	"WES": Manufacturer{'W', "WES", "Westerboer"},
+	"XCS": Manufacturer{'X', "XCS", "XCSoar"},
 	"XYY": Manufacturer{'X', "XYY", "Other manufacturer"},
 	"ZAN": Manufacturer{'Z', "ZAN", "Zander"},
 }
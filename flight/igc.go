@@ -20,7 +20,9 @@
 package flight
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -33,71 +35,208 @@ const (
 	TimeFormat = "150405"
 	// DateFormat is the golang time.Parse format for IGC time
 	DateFormat = "020106"
+	// maxLineSize is the longest record line accepted by the parser.
+	maxLineSize = 1024 * 1024
 )
 
 // ParseIGC returns a Flight object corresponding to the given content.
 // content should be a text string in the IGC format.
+// Parsing is strict, failing on the first invalid record.
 func ParseIGC(content string) (Flight, error) {
-	f := NewFlight()
-	var err error
 	p := IGCParser{}
-	lines := strings.Split(content, "\n")
-	for i := range lines {
-		line := strings.TrimSpace(lines[i])
+	f, _, err := p.Parse(strings.NewReader(content))
+	return f, err
+}
+
+// ParseError is returned by IGCParser when a record fails to be parsed.
+// It holds the position of the offending record in the input.
+type ParseError struct {
+	// Line is the line number of the record (starting at 1).
+	Line int
+	// Offset is the byte offset of the record start in the input.
+	Offset int64
+	// Record is the IGC record type (A, B, C, ...).
+	Record byte
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %v (offset %v, %c record) :: %v", e.Line, e.Offset, e.Record, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+type field struct {
+	start int64
+	end   int64
+	tlc   string
+}
+
+// IGCParser gives functionality to parse IGC flight files.
+//
+// The zero value is ready to use, and parses in strict mode. Parse can be
+// called multiple times on the same parser, but not concurrently.
+type IGCParser struct {
+	// Lenient makes the parser skip invalid records instead of failing,
+	// returning them as warnings. Unknown H records are kept in Header.Extra.
+	Lenient bool
+	// LocalTime makes record times be given in the flight timezone
+	// (Header.Timezone) instead of UTC.
+	LocalTime bool
+	IFields   []field
+	JFields   []field
+	taskDone  bool
+	numSat    int
+	// day, last and timed track the day rollover of record times, location
+	// is the flight timezone.
+	day      int
+	last     time.Duration
+	timed    bool
+	location *time.Location
+	// task holds the C record lines seen so far, and the position of the
+	// first one, until the whole task declaration is available.
+	task       []string
+	taskSize   int
+	taskLine   int
+	taskOffset int64
+}
+
+// Parse reads IGC content from r, returning the corresponding Flight.
+//
+// Content is read and parsed one line at a time, so the whole file is never
+// held in memory. Errors in the content are returned as *ParseError. In
+// lenient mode invalid records are skipped, and returned as warnings.
+func (p *IGCParser) Parse(r io.Reader) (Flight, []*ParseError, error) {
+	f := NewFlight()
+	var warnings []*ParseError
+	p.reset()
+
+	var offset, next int64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			offset = next
+		}
+		next += int64(advance)
+		return advance, token, err
+	})
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
 		// ignore empty lines
-		if len(strings.Trim(line, " ")) < 1 {
+		if len(line) < 1 {
 			continue
 		}
-		switch line[0] {
-		case 'A':
-			err = p.parseA(line, &f)
-		case 'B':
-			err = p.parseB(line, &f)
-		case 'C':
-			if !p.taskDone {
-				err = p.parseC(lines[i:], &f)
+		if line[0] == 'C' && p.task == nil {
+			p.taskLine, p.taskOffset = n, offset
+		}
+		if err := p.parseLine(line, &f); err != nil {
+			perr := &ParseError{Line: n, Offset: offset, Record: line[0], Err: err}
+			if line[0] == 'C' {
+				perr.Line, perr.Offset = p.taskLine, p.taskOffset
+				// give up on the task, the remaining C lines are skipped
+				p.task, p.taskDone = nil, true
 			}
-		case 'D':
-			err = p.parseD(line, &f)
-		case 'E':
-			err = p.parseE(line, &f)
-		case 'F':
-			err = p.parseF(line, &f)
-		case 'G':
-			err = p.parseG(line, &f)
-		case 'H':
-			err = p.parseH(line, &f)
-		case 'I':
-			err = p.parseI(line)
-		case 'J':
-			err = p.parseJ(line)
-		case 'K':
-			err = p.parseK(line, &f)
-		case 'L':
-			err = p.parseL(line, &f)
-		default:
-			err = fmt.Errorf("invalid record :: %v", line)
+			if !p.Lenient {
+				return f, warnings, perr
+			}
+			warnings = append(warnings, perr)
 		}
-		if err != nil {
-			return f, err
+	}
+	if err := scanner.Err(); err != nil {
+		return f, warnings, &ParseError{Line: n + 1, Offset: next, Err: err}
+	}
+	if p.task != nil {
+		perr := &ParseError{Line: p.taskLine, Offset: p.taskOffset, Record: 'C',
+			Err: fmt.Errorf("invalid number of C record lines :: %v", p.task)}
+		if !p.Lenient {
+			return f, warnings, perr
 		}
+		warnings = append(warnings, perr)
 	}
 
-	return f, nil
+	return f, warnings, nil
 }
 
-type field struct {
-	start int64
-	end   int64
-	tlc   string
+// reset clears the state kept from a previous Parse call.
+func (p *IGCParser) reset() {
+	p.IFields, p.JFields = nil, nil
+	p.taskDone, p.numSat = false, 0
+	p.task, p.taskSize = nil, 0
+	p.day, p.last, p.timed, p.location = 0, 0, false, nil
 }
 
-// IGCParser gives functionality to parse IGC flight files.
-type IGCParser struct {
-	IFields  []field
-	JFields  []field
-	taskDone bool
-	numSat   int
+// zeroDate is the date used for record times when the flight has no date.
+var zeroDate = time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseTime returns the absolute time of the given HHMMSS record time.
+//
+// It combines the time with the flight date (Header.Date), moving to the next
+// day each time a record goes back in time more than 12 hours (midnight UTC
+// rollover). Records should be given in file order.
+func (p *IGCParser) parseTime(value string, f *Flight) (time.Time, error) {
+	t, err := time.Parse(TimeFormat, value)
+	if err != nil {
+		return t, err
+	}
+	tod := t.Sub(zeroDate)
+	if p.timed && p.last-tod > 12*time.Hour {
+		p.day++
+	}
+	p.last, p.timed = tod, true
+	date := zeroDate
+	if !f.Header.Date.IsZero() {
+		date = f.Header.Date
+	}
+	t = date.AddDate(0, 0, p.day).Add(tod)
+	if p.LocalTime && p.location != nil {
+		t = t.In(p.location)
+	}
+	return t, nil
+}
+
+// parseLine parses a single (non empty) record line, updating f.
+func (p *IGCParser) parseLine(line string, f *Flight) error {
+	var err error
+	switch line[0] {
+	case 'A':
+		err = p.parseA(line, f)
+	case 'B':
+		err = p.parseB(line, f)
+	case 'C':
+		if !p.taskDone {
+			err = p.addC(line, f)
+		}
+	case 'D':
+		err = p.parseD(line, f)
+	case 'E':
+		err = p.parseE(line, f)
+	case 'F':
+		err = p.parseF(line, f)
+	case 'G':
+		err = p.parseG(line, f)
+	case 'H':
+		err = p.parseH(line, f)
+	case 'I':
+		err = p.parseI(line)
+	case 'J':
+		err = p.parseJ(line)
+	case 'K':
+		err = p.parseK(line, f)
+	case 'L':
+		err = p.parseL(line, f)
+	default:
+		err = fmt.Errorf("invalid record :: %v", line)
+	}
+	return err
 }
 
 func (p *IGCParser) parseA(line string, f *Flight) error {
@@ -111,12 +250,12 @@ func (p *IGCParser) parseA(line string, f *Flight) error {
 }
 
 func (p *IGCParser) parseB(line string, f *Flight) error {
-	if len(line) < 37 {
+	if len(line) < bBaseSize {
 		return fmt.Errorf("line too short :: %v", line)
 	}
 	pt := NewPoint()
 	var err error
-	pt.Time, err = time.Parse(TimeFormat, line[1:7])
+	pt.Time, err = p.parseTime(line[1:7], f)
 	if err != nil {
 		return err
 	}
@@ -136,6 +275,9 @@ func (p *IGCParser) parseB(line string, f *Flight) error {
 		return err
 	}
 	for _, f := range p.IFields {
+		if f.start < 1 || int(f.end) > len(line) || f.start > f.end {
+			return fmt.Errorf("line too short for I record fields :: %v", line)
+		}
 		pt.IData[f.tlc] = line[f.start-1 : f.end]
 	}
 	pt.NumSatellites = p.numSat
@@ -143,6 +285,28 @@ func (p *IGCParser) parseB(line string, f *Flight) error {
 	return nil
 }
 
+// addC buffers the given C record line, parsing the whole task declaration
+// once all its lines are available.
+func (p *IGCParser) addC(line string, f *Flight) error {
+	if p.task == nil {
+		if len(line) < 25 {
+			return fmt.Errorf("wrong line size :: %v", line)
+		}
+		nTP, err := strconv.Atoi(line[23:25])
+		if err != nil {
+			return fmt.Errorf("invalid number of turnpoints :: %v", line)
+		}
+		p.taskSize = 5 + nTP
+	}
+	p.task = append(p.task, line)
+	if len(p.task) < p.taskSize {
+		return nil
+	}
+	err := p.parseC(p.task, f)
+	p.task = nil
+	return err
+}
+
 func (p *IGCParser) parseC(lines []string, f *Flight) error {
 	line := lines[0]
 	if len(line) < 25 {
@@ -214,7 +378,7 @@ func (p *IGCParser) parseE(line string, f *Flight) error {
 	if len(line) < 10 {
 		return fmt.Errorf("line too short :: %v", line)
 	}
-	t, err := time.Parse(TimeFormat, line[1:7])
+	t, err := p.parseTime(line[1:7], f)
 	if err != nil {
 		return err
 	}
@@ -229,7 +393,7 @@ func (p *IGCParser) parseF(line string, f *Flight) error {
 	if len(line) < 7 {
 		return fmt.Errorf("line too short :: %v", line)
 	}
-	t, err := time.Parse(TimeFormat, line[1:7])
+	t, err := p.parseTime(line[1:7], f)
 	if err != nil {
 		return err
 	}
@@ -248,7 +412,10 @@ func (p *IGCParser) parseF(line string, f *Flight) error {
 }
 
 func (p *IGCParser) parseG(line string, f *Flight) error {
-	f.Signature = f.Signature + line[1:]
+	if f.Signature != "" {
+		f.Signature += "\n"
+	}
+	f.Signature += line[1:]
 	return nil
 }
 
@@ -260,15 +427,24 @@ func (p *IGCParser) parseH(line string, f *Flight) error {
 
 	switch line[2:5] {
 	case "DTE":
-		if len(line) < 11 {
+		// HFDTEDDMMYY or (2016+) HFDTEDATE:DDMMYY,NN
+		value := strings.TrimSpace(stripUpTo(line[5:], ":"))
+		if len(value) < 6 {
 			return fmt.Errorf("line too short :: %v", line)
 		}
-		f.Header.Date, err = time.Parse(DateFormat, line[5:11])
+		f.Header.Date, err = time.Parse(DateFormat, value[:6])
+		if err == nil && len(value) > 6 {
+			if value[6] != ',' {
+				return fmt.Errorf("invalid date :: %v", line)
+			}
+			f.Header.FlightNumber, err = strconv.Atoi(strings.TrimSpace(value[7:]))
+		}
 	case "FXA":
-		if len(line) < 8 {
+		value := strings.TrimSpace(stripUpTo(line[5:], ":"))
+		if len(value) < 3 {
 			return fmt.Errorf("line too short :: %v", line)
 		}
-		f.Header.FixAccuracy, err = strconv.ParseInt(line[5:8], 10, 64)
+		f.Header.FixAccuracy, err = strconv.ParseInt(value[:3], 10, 64)
 	case "PLT":
 		f.Header.Pilot = stripUpTo(line[5:], ":")
 	case "CM2":
@@ -289,7 +465,13 @@ func (p *IGCParser) parseH(line string, f *Flight) error {
 	case "FTY":
 		f.Header.FlightRecorder = stripUpTo(line[5:], ":")
 	case "GPS":
+		// the receiver maker can be followed by a colon, so only the
+		// long name given in recent files (RECEIVER:) is stripped
 		f.Header.GPS = line[5:]
+		if i := strings.Index(f.Header.GPS, ":"); i != -1 &&
+			strings.EqualFold(strings.TrimSpace(f.Header.GPS[:i]), "RECEIVER") {
+			f.Header.GPS = f.Header.GPS[i+1:]
+		}
 	case "PRS":
 		f.Header.PressureSensor = stripUpTo(line[5:], ":")
 	case "CID":
@@ -301,9 +483,16 @@ func (p *IGCParser) parseH(line string, f *Flight) error {
 		if err != nil {
 			return err
 		}
-		f.Header.Timezone = *time.FixedZone("", int(z*3600))
+		p.location = time.FixedZone("", int(z*3600))
+		f.Header.Timezone = *p.location
 	default:
-		err = fmt.Errorf("unknown record :: %v", line)
+		if !p.Lenient {
+			return fmt.Errorf("unknown record :: %v", line)
+		}
+		if f.Header.Extra == nil {
+			f.Header.Extra = make(map[string]string)
+		}
+		f.Header.Extra[line[1:5]] = stripUpTo(line[5:], ":")
 	}
 
 	return err
@@ -355,12 +544,15 @@ func (p *IGCParser) parseK(line string, f *Flight) error {
 	if len(line) < 7 {
 		return fmt.Errorf("line too short :: %v", line)
 	}
-	t, err := time.Parse(TimeFormat, line[1:7])
+	t, err := p.parseTime(line[1:7], f)
 	if err != nil {
 		return err
 	}
 	fields := make(map[string]string)
 	for _, f := range p.JFields {
+		if f.start < 1 || int(f.end) > len(line) || f.start > f.end {
+			return fmt.Errorf("line too short for J record fields :: %v", line)
+		}
 		fields[f.tlc] = line[f.start-1 : f.end]
 	}
 	f.K[t] = fields
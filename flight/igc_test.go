@@ -21,7 +21,9 @@ package flight
 
 import (
 	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -72,6 +74,30 @@ HFTZNTimezone:2.00
 		},
 		false,
 	},
+	{
+		"long form header test",
+		`
+HFDTEDATE:150616,02
+HFFXAFIXACCURACY:035
+HFGPSRECEIVER:uBLOX NEO-6G,16,max9000m
+HFTZNTIMEZONE:+10.50
+HFDTMGPSDATUM:WGS84
+HFPLTPILOTINCHARGE:EZ PILOT
+`,
+		Flight{
+			Header: Header{
+				Date:         time.Date(2016, time.June, 15, 0, 0, 0, 0, time.UTC),
+				FlightNumber: 2, FixAccuracy: 35, GPS: "uBLOX NEO-6G,16,max9000m",
+				Timezone: *time.FixedZone("", 10*3600+1800), GPSDatum: "WGS84",
+				Pilot: "EZ PILOT",
+			},
+			K:          map[time.Time]map[string]string{},
+			Events:     map[time.Time]map[string]string{},
+			Satellites: map[time.Time][]int{},
+			Sources:    make(map[string]Source),
+		},
+		false,
+	},
 	{"A record failure too short",
 		"AFLA0", Flight{}, true},
 	{"H record failure too short",
@@ -80,6 +106,12 @@ HFTZNTimezone:2.00
 		"HFDTE330203", Flight{}, true},
 	{"H record failure date too short",
 		"HFDTE33", Flight{}, true},
+	{"H record failure long form date too short",
+		"HFDTEDATE:1506", Flight{}, true},
+	{"H record failure long form bad flight number",
+		"HFDTEDATE:150616,aa", Flight{}, true},
+	{"H record failure long form bad date separator",
+		"HFDTEDATE:150616-01", Flight{}, true},
 	{"H record failure bad fix accuracy",
 		"HFFXAAAA", Flight{}, true},
 	{"H record failure fix accuracy too short",
@@ -176,13 +208,51 @@ GJNJK2489IERGNV3089IVJE9GO398535J3894N358954983O0934
 				Description: "500KTri",
 			},
 			DGPSStationID: "0331",
-			Signature:     "REJNGJERJKNJKRE31895478537H43982FJN9248F942389T433TJNJK2489IERGNV3089IVJE9GO398535J3894N358954983O0934",
+			Signature:     "REJNGJERJKNJKRE31895478537H43982FJN9248F942389T433T\nJNJK2489IERGNV3089IVJE9GO398535J3894N358954983O0934",
 			Sources:       make(map[string]Source),
 		},
 		false,
 	},
+	{
+		"point/fix without extensions",
+		"B1602455107126N00149300WA0028800429",
+		Flight{
+			Points: []Point{
+				Point{
+					Time:     time.Date(0, 1, 1, 16, 2, 45, 0, time.UTC),
+					Latitude: 51.118766666666666, Longitude: -1.8216666666666668, FixValidity: 'A',
+					PressureAltitude: 288, GNSSAltitude: 429, IData: map[string]string{},
+				},
+			},
+			K:          map[time.Time]map[string]string{},
+			Events:     map[time.Time]map[string]string{},
+			Satellites: map[time.Time][]int{},
+			Sources:    make(map[string]Source),
+		},
+		false,
+	},
+	{
+		"point/fix with undeclared extension",
+		"B1602455107126N00149300WA00288004291",
+		Flight{
+			Points: []Point{
+				Point{
+					Time:     time.Date(0, 1, 1, 16, 2, 45, 0, time.UTC),
+					Latitude: 51.118766666666666, Longitude: -1.8216666666666668, FixValidity: 'A',
+					PressureAltitude: 288, GNSSAltitude: 429, IData: map[string]string{},
+				},
+			},
+			K:          map[time.Time]map[string]string{},
+			Events:     map[time.Time]map[string]string{},
+			Satellites: map[time.Time][]int{},
+			Sources:    make(map[string]Source),
+		},
+		false,
+	},
 	{"point/fix wrong size",
 		"B110001", Flight{}, true},
+	{"point/fix one byte short",
+		"B1602455107126N00149300WA002880042", Flight{}, true},
 	{"point/fix bad time",
 		"B3103105107212N00149174WV002930043519608024", Flight{}, true},
 	{"point/fix bad fix validity",
@@ -315,6 +385,168 @@ func TestIGCParse(t *testing.T) {
 	}
 }
 
+type ParseErrorTest struct {
+	t string
+	c string
+	r ParseError
+}
+
+var parseErrorTests = []ParseErrorTest{
+	{"bad record in the middle",
+		"AFLA001\nHFDTE010203\nB1603105107212N00149174WX002930043519608024\n",
+		ParseError{Line: 3, Offset: 20, Record: 'B'}},
+	{"crlf line endings and empty lines",
+		"AFLA001\r\n\r\nHFDTE010203\r\nRANDOM GARBAGE\r\n",
+		ParseError{Line: 4, Offset: 24, Record: 'R'}},
+	{"point shorter than declared extensions",
+		"I013638FXA\nB1603105107212N00149174WA0029300435",
+		ParseError{Line: 2, Offset: 11, Record: 'B'}},
+	{"task with a bad turnpoint",
+		"AFLA001\nC150701213841160701000101500KTri\nC5111359N00101899WEZ TAKEOFF\nC5110179N00102644WEZ START\nC5209092N00255227\nC5110179N00102644WEZ FINISH\nC5111359N00101899WEZ LANDING",
+		ParseError{Line: 2, Offset: 8, Record: 'C'}},
+	{"task with missing lines",
+		"AFLA001\nC150701213841160701000101500KTri\nC5111359N00101899WEZ TAKEOFF\n",
+		ParseError{Line: 2, Offset: 8, Record: 'C'}},
+}
+
+func TestIGCParseError(t *testing.T) {
+	for _, test := range parseErrorTests {
+		_, err := ParseIGC(test.c)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%v failed :: expected a *ParseError got %v", test.t, err)
+			continue
+		}
+		if perr.Line != test.r.Line || perr.Offset != test.r.Offset || perr.Record != test.r.Record {
+			t.Errorf("%v failed :: expected %+v got %+v", test.t, test.r, *perr)
+			continue
+		}
+		if perr.Err == nil || !strings.Contains(perr.Error(), perr.Err.Error()) {
+			t.Errorf("%v failed :: missing underlying error in %v", test.t, perr)
+		}
+	}
+}
+
+func TestIGCParserReader(t *testing.T) {
+	c, err := ioutil.ReadFile("../netcoupe/t/sample-flight.igc")
+	if err != nil {
+		t.Fatalf("failed to load sample flight :: %v", err)
+	}
+	expected, err := ParseIGC(string(c))
+	if err != nil {
+		t.Fatalf("failed to parse sample flight :: %v", err)
+	}
+	file, err := os.Open("../netcoupe/t/sample-flight.igc")
+	if err != nil {
+		t.Fatalf("failed to open sample flight :: %v", err)
+	}
+	defer file.Close()
+	p := IGCParser{}
+	result, _, err := p.Parse(file)
+	if err != nil {
+		t.Fatalf("failed to parse sample flight from reader :: %v", err)
+	}
+	if len(result.Points) != 10527 {
+		t.Errorf("expected 10527 points got %v", len(result.Points))
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("flight parsed from reader differs from the one parsed from string")
+	}
+	// parser can be reused
+	result, _, err = p.Parse(strings.NewReader(string(c)))
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("failed to reuse parser :: %v", err)
+	}
+}
+
+func TestIGCParseLenient(t *testing.T) {
+	content := `AXCS001
+HFDTE010203
+HFALGALTGPS:GEO
+HFALPALTPRESSURE:ISA
+HOSITSite:EZ SITE
+LPL
+B1603105107212N00149174WX002930043519608024
+B1603105107212N00149174WA002930043519608024
+C150701213841160701000102500KTri
+RANDOM GARBAGE
+`
+	p := IGCParser{}
+	if _, _, err := p.Parse(strings.NewReader(content)); err == nil {
+		t.Errorf("expected strict parsing to fail")
+	}
+	p.Lenient = true
+	result, warnings, err := p.Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("lenient parsing failed :: %v", err)
+	}
+	expected := map[string]string{"FALG": "GEO", "FALP": "ISA", "OSIT": "EZ SITE"}
+	if !reflect.DeepEqual(result.Header.Extra, expected) {
+		t.Errorf("expected extra headers %v got %v", expected, result.Header.Extra)
+	}
+	if len(result.Points) != 1 {
+		t.Errorf("expected 1 point got %v", len(result.Points))
+	}
+	lines := []int{6, 7, 10, 9}
+	if len(warnings) != len(lines) {
+		t.Fatalf("expected %v warnings got %v :: %v", len(lines), len(warnings), warnings)
+	}
+	for i, w := range warnings {
+		if w.Line != lines[i] {
+			t.Errorf("expected warning at line %v got %v", lines[i], w)
+		}
+	}
+}
+
+func TestIGCParseMidnightRollover(t *testing.T) {
+	content := `AFLA001
+HFDTE311215
+HFTZNTimezone:-3.00
+I013637SIU
+J010812HDT
+B2359555107126N00149300WA002880042909
+F235958040609
+E000001PEV
+B0000055107212N00149174WA002930043508
+K00000800090
+B0000045107212N00149174WA002930043508
+`
+	p := IGCParser{}
+	result, _, err := p.Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to parse flight :: %v", err)
+	}
+	expected := []time.Time{
+		time.Date(2015, time.December, 31, 23, 59, 55, 0, time.UTC),
+		time.Date(2016, time.January, 1, 0, 0, 5, 0, time.UTC),
+		time.Date(2016, time.January, 1, 0, 0, 4, 0, time.UTC),
+	}
+	for i, pt := range result.Points {
+		if !pt.Time.Equal(expected[i]) {
+			t.Errorf("expected point time %v got %v", expected[i], pt.Time)
+		}
+	}
+	if _, ok := result.Satellites[time.Date(2015, time.December, 31, 23, 59, 58, 0, time.UTC)]; !ok {
+		t.Errorf("missing satellites record :: %v", result.Satellites)
+	}
+	if _, ok := result.Events[time.Date(2016, time.January, 1, 0, 0, 1, 0, time.UTC)]; !ok {
+		t.Errorf("missing event record :: %v", result.Events)
+	}
+	if _, ok := result.K[time.Date(2016, time.January, 1, 0, 0, 8, 0, time.UTC)]; !ok {
+		t.Errorf("missing k record :: %v", result.K)
+	}
+
+	p.LocalTime = true
+	result, _, err = p.Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to parse flight :: %v", err)
+	}
+	local := result.Points[1].Time
+	if !local.Equal(expected[1]) || local.Hour() != 21 || local.Day() != 31 {
+		t.Errorf("expected local time for %v got %v", expected[1], local)
+	}
+}
+
 func TestStripUpToMissing(t *testing.T) {
 	s := "nocolonhere"
 	r := stripUpTo(s, ":")
@@ -335,6 +567,18 @@ func BenchmarkIGCParse(b *testing.B) {
 	}
 }
 
+func BenchmarkIGCParserReader(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open("../netcoupe/t/sample-flight.igc")
+		if err != nil {
+			b.Fatalf("failed to open sample flight :: %v", err)
+		}
+		p := IGCParser{}
+		p.Parse(file)
+		file.Close()
+	}
+}
+
 func getFlight(task Task) Flight {
 	f := NewFlight()
 	f.Task = task
@@ -0,0 +1,289 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+const (
+	// bBaseSize is the size of a B record without extensions.
+	bBaseSize = 35
+	// kBaseSize is the size of a K record without extensions.
+	kBaseSize = 7
+)
+
+// WriteIGC writes the given flight to w in the IGC format.
+//
+// Records are written in the order defined in the IGC spec: A, H, I, J, C
+// and D, then the time ordered E, F, B and K records, L and finally G.
+// Extensions in I and J records are written sorted by their three letter
+// code, with the width of the longest value found.
+func WriteIGC(w io.Writer, f Flight) error {
+	iw := igcWriter{w: bufio.NewWriter(w)}
+	iw.writeA(f)
+	iw.writeH(f.Header)
+	ifields := iw.writeIJ('I', bBaseSize, pointData(f.Points))
+	jfields := iw.writeIJ('J', kBaseSize, kData(f.K))
+	iw.writeC(f.Task)
+	if f.DGPSStationID != "" {
+		iw.line("D2%v", f.DGPSStationID)
+	}
+	iw.writeTimed(f, ifields, jfields)
+	for _, l := range f.Logbook {
+		iw.line("L%v%v", l.Type, l.Text)
+	}
+	if f.Signature != "" {
+		for _, g := range strings.Split(f.Signature, "\n") {
+			iw.line("G%v", g)
+		}
+	}
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+// igcWriter writes IGC record lines, keeping the first error found.
+type igcWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a single record line, terminated by CRLF.
+func (iw *igcWriter) line(format string, a ...interface{}) {
+	if iw.err != nil {
+		return
+	}
+	_, iw.err = fmt.Fprintf(iw.w, format+"\r\n", a...)
+}
+
+func (iw *igcWriter) writeA(f Flight) {
+	m, id := f.Header.Manufacturer, f.Header.UniqueID
+	if m == "" {
+		m = "XYY"
+	}
+	if id == "" {
+		id = "000"
+	}
+	iw.line("A%v%v%v", m, id, f.Header.AdditionalData)
+}
+
+func (iw *igcWriter) writeH(h Header) {
+	if !h.Date.IsZero() {
+		if h.FlightNumber > 0 {
+			iw.line("HFDTEDATE:%v,%02d", h.Date.Format(DateFormat), h.FlightNumber)
+		} else {
+			iw.line("HFDTE%v", h.Date.Format(DateFormat))
+		}
+	}
+	if h.FixAccuracy != 0 {
+		iw.line("HFFXA%03d", h.FixAccuracy)
+	}
+	fields := []struct {
+		tlc   string
+		value string
+	}{
+		{"PLTPILOTINCHARGE", h.Pilot}, {"CM2CREW2", h.Crew},
+		{"GTYGLIDERTYPE", h.GliderType}, {"GIDGLIDERID", h.GliderID},
+		{"DTM100GPSDATUM", h.GPSDatum}, {"RFWFIRMWAREVERSION", h.FirmwareVersion},
+		{"RHWHARDWAREVERSION", h.HardwareVersion}, {"FTYFRTYPE", h.FlightRecorder},
+		{"GPSRECEIVER", h.GPS}, {"PRSPRESSALTSENSOR", h.PressureSensor},
+		{"CIDCOMPETITIONID", h.CompetitionID}, {"CCLCOMPETITIONCLASS", h.CompetitionClass},
+	}
+	for _, field := range fields {
+		if field.value != "" {
+			iw.line("HF%v:%v", field.tlc, field.value)
+		}
+	}
+	if !reflect.DeepEqual(h.Timezone, time.Location{}) {
+		_, offset := time.Time{}.In(&h.Timezone).Zone()
+		iw.line("HFTZNTIMEZONE:%.2f", float64(offset)/3600)
+	}
+	for _, key := range sortedKeys(h.Extra) {
+		iw.line("H%v:%v", key, h.Extra[key])
+	}
+}
+
+// writeIJ writes an I or J record declaring the given extension data, where
+// data maps each three letter code to the width of its values. Extensions
+// start right after base bytes. It returns the corresponding fields.
+func (iw *igcWriter) writeIJ(record byte, base int64, data map[string]int) []field {
+	if len(data) == 0 {
+		return nil
+	}
+	var fields []field
+	s := fmt.Sprintf("%c%02d", record, len(data))
+	start := base + 1
+	for _, tlc := range sortedKeys(data) {
+		end := start + int64(data[tlc]) - 1
+		fields = append(fields, field{start: start, end: end, tlc: tlc})
+		s += fmt.Sprintf("%02d%02d%v", start, end, tlc)
+		start = end + 1
+	}
+	iw.line("%v", s)
+	return fields
+}
+
+func (iw *igcWriter) writeC(t Task) {
+	if reflect.DeepEqual(t, Task{}) {
+		return
+	}
+	declaration, date := "000000000000", "000000"
+	if !t.DeclarationDate.IsZero() {
+		declaration = t.DeclarationDate.Format(DateFormat + TimeFormat)
+	}
+	if !t.FlightDate.IsZero() {
+		date = t.FlightDate.Format(DateFormat)
+	}
+	iw.line("C%v%v%04d%02d%v", declaration, date, t.Number, len(t.Turnpoints), t.Description)
+	points := append([]Point{t.Takeoff, t.Start}, t.Turnpoints...)
+	points = append(points, t.Finish, t.Landing)
+	for _, p := range points {
+		iw.line("C%v%v%v", spatial.Decimal2DMD(p.Latitude, true),
+			spatial.Decimal2DMD(p.Longitude, false), p.Description)
+	}
+}
+
+// timedRecord is an E, F or K record waiting to be written.
+// For F records, sats holds the number of satellites.
+type timedRecord struct {
+	time  time.Time
+	order int
+	line  string
+	sats  int
+}
+
+// writeTimed writes the B records, in the order they are in f.Points, with
+// the E, F and K records interleaved according to their time. For the same
+// time, F and E records come before B and K after it, unless the F record
+// does not match the number of satellites in the point (it was after it).
+func (iw *igcWriter) writeTimed(f Flight, ifields []field, jfields []field) {
+	var records []timedRecord
+	for t, events := range f.Events {
+		for _, tlc := range sortedKeys(events) {
+			records = append(records, timedRecord{t, 1, fmt.Sprintf("E%v%v%v", igcTime(t), tlc, events[tlc]), 0})
+		}
+	}
+	for t, sats := range f.Satellites {
+		s := "F" + igcTime(t)
+		for _, n := range sats {
+			s += fmt.Sprintf("%02d", n)
+		}
+		records = append(records, timedRecord{t, 0, s, len(sats)})
+	}
+	for t, data := range f.K {
+		records = append(records, timedRecord{t, 3, "K" + igcTime(t) + extensionData(jfields, data), 0})
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].time.Equal(records[j].time) {
+			return records[i].time.Before(records[j].time)
+		}
+		if records[i].order != records[j].order {
+			return records[i].order < records[j].order
+		}
+		return records[i].line < records[j].line
+	})
+
+	next := 0
+	for _, p := range f.Points {
+		for ; next < len(records); next++ {
+			r := records[next]
+			if r.time.After(p.Time) || (r.time.Equal(p.Time) &&
+				(r.order > 2 || (r.order == 0 && r.sats != p.NumSatellites))) {
+				break
+			}
+			iw.line("%v", r.line)
+		}
+		validity := p.FixValidity
+		if validity == 0 {
+			validity = 'A'
+		}
+		iw.line("B%v%v%v%c%05d%05d%v", igcTime(p.Time), spatial.Decimal2DMD(p.Latitude, true),
+			spatial.Decimal2DMD(p.Longitude, false), validity, p.PressureAltitude,
+			p.GNSSAltitude, extensionData(ifields, p.IData))
+	}
+	for ; next < len(records); next++ {
+		iw.line("%v", records[next].line)
+	}
+}
+
+// igcTime returns the IGC (HHMMSS, UTC) representation of t.
+func igcTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// extensionData returns the extension values in data, following fields.
+// Missing or short values are zero padded on the left.
+func extensionData(fields []field, data map[string]string) string {
+	s := ""
+	for _, f := range fields {
+		v := data[f.tlc]
+		for int64(len(v)) < f.end-f.start+1 {
+			v = "0" + v
+		}
+		s += v
+	}
+	return s
+}
+
+// pointData returns the width of each extension found in the given points.
+func pointData(points []Point) map[string]int {
+	widths := make(map[string]int)
+	for _, p := range points {
+		for tlc, v := range p.IData {
+			if len(v) > widths[tlc] {
+				widths[tlc] = len(v)
+			}
+		}
+	}
+	return widths
+}
+
+// kData returns the width of each extension found in the given K records.
+func kData(k map[time.Time]map[string]string) map[string]int {
+	widths := make(map[string]int)
+	for _, data := range k {
+		for tlc, v := range data {
+			if len(v) > widths[tlc] {
+				widths[tlc] = len(v)
+			}
+		}
+	}
+	return widths
+}
+
+// sortedKeys returns the keys of the given map (of string keys) sorted.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteIGC(t *testing.T) {
+	f := NewFlight()
+	f.Header = Header{
+		Manufacturer: "FLA", UniqueID: "001", Date: time.Date(2003, time.February, 1, 0, 0, 0, 0, time.UTC),
+		FlightNumber: 2, FixAccuracy: 35, Pilot: "EZ PILOT", GliderType: "EZ TYPE",
+		Timezone: *time.FixedZone("", -3*3600), Extra: map[string]string{"OSIT": "EZ SITE", "FALG": "GEO"},
+	}
+	t1 := time.Date(2003, time.February, 1, 16, 2, 45, 0, time.UTC)
+	t2 := time.Date(2003, time.February, 1, 16, 3, 10, 0, time.UTC)
+	f.Points = []Point{
+		Point{Time: t1, Latitude: 51.118766666666666, Longitude: -1.8216666666666668, FixValidity: 'A',
+			PressureAltitude: 288, GNSSAltitude: 429, IData: map[string]string{"SIU": "09", "ENL": "020"},
+			NumSatellites: 2},
+		Point{Time: t2, Latitude: -51.1202, Longitude: 1.8195666666666668, FixValidity: 'V',
+			PressureAltitude: -12, GNSSAltitude: 435, IData: map[string]string{"SIU": "08", "ENL": "024"},
+			NumSatellites: 2},
+	}
+	f.Events[t1] = map[string]string{"PEV": ""}
+	f.Satellites[t1] = []int{4, 6}
+	f.K[t2] = map[string]string{"HDT": "00090"}
+	f.Logbook = []LogEntry{LogEntry{Type: "PLT", Text: "LOG TEXT"}}
+	f.Signature = strings.Repeat("A", 80) + "\nAAAAA"
+
+	expected := "AFLA001\r\n" +
+		"HFDTEDATE:010203,02\r\nHFFXA035\r\nHFPLTPILOTINCHARGE:EZ PILOT\r\n" +
+		"HFGTYGLIDERTYPE:EZ TYPE\r\nHFTZNTIMEZONE:-3.00\r\n" +
+		"HFALG:GEO\r\nHOSIT:EZ SITE\r\n" +
+		"I023638ENL3940SIU\r\nJ010812HDT\r\n" +
+		"F1602450406\r\nE160245PEV\r\n" +
+		"B1602455107126N00149300WA002880042902009\r\n" +
+		"B1603105107212S00149174EV-00120043502408\r\n" +
+		"K16031000090\r\n" +
+		"LPLTLOG TEXT\r\n" +
+		"G" + strings.Repeat("A", 80) + "\r\nGAAAAA\r\n"
+	var b bytes.Buffer
+	if err := WriteIGC(&b, f); err != nil {
+		t.Fatalf("failed to write flight :: %v", err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, b.String())
+	}
+}
+
+func TestWriteIGCRoundTrip(t *testing.T) {
+	tests := []string{"../netcoupe/t/sample-flight.igc"}
+	for _, test := range parseTests {
+		if !test.e {
+			tests = append(tests, test.c)
+		}
+	}
+	for _, test := range tests {
+		content := test
+		if strings.HasSuffix(test, ".igc") {
+			c, err := ioutil.ReadFile(test)
+			if err != nil {
+				t.Fatalf("failed to load %v :: %v", test, err)
+			}
+			content = string(c)
+		}
+		expected, err := ParseIGC(content)
+		if err != nil {
+			t.Errorf("failed to parse flight :: %v", err)
+			continue
+		}
+		var b bytes.Buffer
+		if err = WriteIGC(&b, expected); err != nil {
+			t.Errorf("failed to write flight :: %v", err)
+			continue
+		}
+		result, err := ParseIGC(b.String())
+		if err != nil {
+			t.Errorf("failed to parse written flight :: %v", err)
+			continue
+		}
+		// A record is always written
+		if expected.Header.Manufacturer == "" {
+			result.Header.Manufacturer, result.Header.UniqueID = "", ""
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("round trip failed, expected\n%+v\ngot\n%+v", expected.Header, result.Header)
+		}
+	}
+}
+
+type failWriter struct{}
+
+func (w failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriteIGCFailure(t *testing.T) {
+	f, err := ParseIGC(parseTests[0].c)
+	if err != nil {
+		t.Fatalf("failed to parse flight :: %v", err)
+	}
+	if err := WriteIGC(failWriter{}, f); err == nil {
+		t.Errorf("expected write to fail")
+	}
+}
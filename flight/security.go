@@ -0,0 +1,156 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Validity is the result of checking the security (G record) of a flight.
+type Validity int
+
+const (
+	// Unsupported means no validator is available for the flight recorder.
+	Unsupported Validity = iota
+	// Valid means the flight data matches the signature.
+	Valid
+	// Invalid means the flight data does not match the signature.
+	Invalid
+)
+
+func (v Validity) String() string {
+	switch v {
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	default:
+		return "unsupported"
+	}
+}
+
+// Validator checks the G record security of IGC files.
+//
+// Each manufacturer defines its own security scheme, approved by the IGC
+// (Appendix G of the IGC spec). XCSValidator is registered by default, other
+// validators are added with RegisterValidator.
+type Validator interface {
+	// Validate checks the given signature (the content of the G records)
+	// against the signed data (the canonical record set of the file).
+	Validate(data []byte, signature string) (bool, error)
+}
+
+// validators holds the registered validators, keyed by manufacturer.
+var validators = struct {
+	sync.RWMutex
+	m map[string]Validator
+}{m: map[string]Validator{"XCS": XCSValidator{}}}
+
+// RegisterValidator sets the validator for the given manufacturer, which
+// must be one of the keys in Manufacturers. A nil validator removes it.
+func RegisterValidator(manufacturer string, v Validator) error {
+	if _, ok := Manufacturers[manufacturer]; !ok {
+		return fmt.Errorf("unknown manufacturer :: %v", manufacturer)
+	}
+	validators.Lock()
+	defer validators.Unlock()
+	if v == nil {
+		delete(validators.m, manufacturer)
+	} else {
+		validators.m[manufacturer] = v
+	}
+	return nil
+}
+
+// ValidateIGC checks the security of the IGC content in r, using the
+// validator registered for the manufacturer in its A record. The result is
+// Unsupported if there is none.
+func ValidateIGC(r io.Reader) (Validity, error) {
+	manufacturer, data, signature, err := SignedData(r)
+	if err != nil {
+		return Unsupported, err
+	}
+	validators.RLock()
+	v, ok := validators.m[manufacturer]
+	validators.RUnlock()
+	if !ok {
+		return Unsupported, nil
+	}
+	valid, err := v.Validate(data, signature)
+	if err != nil {
+		return Unsupported, err
+	}
+	if !valid {
+		return Invalid, nil
+	}
+	return Valid, nil
+}
+
+// SignedData returns the manufacturer, the signed data and the signature of
+// the IGC content in r.
+//
+// The signed data follows the records defined in Appendix A of the IGC spec:
+// all records except G, H records not recorded by the flight recorder (data
+// source other than F) and L records not from the manufacturer. Each record
+// is terminated by CRLF, and empty lines are ignored.
+func SignedData(r io.Reader) (string, []byte, string, error) {
+	var manufacturer, signature string
+	var data bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		switch line[0] {
+		case 'A':
+			if len(line) < 4 {
+				return "", nil, "", fmt.Errorf("line too short :: %v", line)
+			}
+			manufacturer = line[1:4]
+		case 'G':
+			signature += strings.TrimSpace(line[1:])
+			continue
+		case 'H':
+			if len(line) < 2 || line[1] != 'F' {
+				continue
+			}
+		case 'L':
+			if len(line) < 4 || line[1:4] != manufacturer {
+				continue
+			}
+		}
+		data.WriteString(line + "\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, "", err
+	}
+	if manufacturer == "" {
+		return "", nil, "", errors.New("missing A record")
+	}
+	return manufacturer, data.Bytes(), signature, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const securityTestContent = `AXYY001
+HFDTE010203
+HOSITSite:EZ SITE
+HPCM2Crew2:
+I013638ENL
+B1602455107126N00149300WA002880042902
+LXYYMANUFACTURER LOG
+LPLTPILOT LOG
+B1603105107212N00149174WV002930043502
+`
+
+func TestSignedData(t *testing.T) {
+	m, data, sig, err := SignedData(strings.NewReader(strings.Replace(securityTestContent, "\n", "\r\n", -1) + "GAB12\r\nG34\r\n"))
+	if err != nil {
+		t.Fatalf("failed to get signed data :: %v", err)
+	}
+	expected := "AXYY001\r\nHFDTE010203\r\nI013638ENL\r\n" +
+		"B1602455107126N00149300WA002880042902\r\nLXYYMANUFACTURER LOG\r\n" +
+		"B1603105107212N00149174WV002930043502\r\n"
+	if m != "XYY" || string(data) != expected || sig != "AB1234" {
+		t.Errorf("expected %v\n%v\n%v got %v\n%v\n%v", "XYY", expected, "AB1234", m, string(data), sig)
+	}
+	if _, _, _, err = SignedData(strings.NewReader("HFDTE010203\n")); err == nil {
+		t.Errorf("expected missing A record to fail")
+	}
+}
+
+// hashValidator accepts signatures holding the hex encoded SHA-256 digest
+// of the signed data.
+type hashValidator struct{}
+
+func (v hashValidator) Validate(data []byte, signature string) (bool, error) {
+	return strings.EqualFold(signature, hashSignature(data)), nil
+}
+
+func hashSignature(data []byte) string {
+	digest := sha256.Sum256(data)
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+func TestValidateIGC(t *testing.T) {
+	if err := RegisterValidator("XYY", hashValidator{}); err != nil {
+		t.Fatalf("failed to register validator :: %v", err)
+	}
+	defer RegisterValidator("XYY", nil)
+
+	_, data, _, _ := SignedData(strings.NewReader(securityTestContent))
+	sig := hashSignature(data)
+	content := securityTestContent
+	for i := 0; i < len(sig); i += 75 {
+		end := i + 75
+		if end > len(sig) {
+			end = len(sig)
+		}
+		content += "G" + sig[i:end] + "\n"
+	}
+
+	tests := []struct {
+		t string
+		c string
+		r Validity
+	}{
+		{"untouched file", content, Valid},
+		{"crlf line endings", strings.Replace(content, "\n", "\r\n", -1), Valid},
+		{"changed pilot log", strings.Replace(content, "PILOT LOG", "OTHER LOG", 1), Valid},
+		{"changed fix", strings.Replace(content, "WV00293", "WV00294", 1), Invalid},
+		{"changed manufacturer log", strings.Replace(content, "MANUFACTURER", "OTHER", 1), Invalid},
+		{"garbage signature", securityTestContent + "GNOTHEX\n", Invalid},
+		{"no validator", strings.Replace(content, "AXYY", "ALXN", 1), Unsupported},
+	}
+	for _, test := range tests {
+		r, err := ValidateIGC(strings.NewReader(test.c))
+		if err != nil {
+			t.Errorf("%v failed :: %v", test.t, err)
+			continue
+		}
+		if r != test.r {
+			t.Errorf("%v failed :: expected %v got %v", test.t, test.r, r)
+		}
+	}
+}
+
+func TestRegisterValidatorUnknown(t *testing.T) {
+	if err := RegisterValidator("ZZZ", hashValidator{}); err == nil {
+		t.Errorf("expected unknown manufacturer to fail")
+	}
+}
+
+func TestValidateIGCXCS(t *testing.T) {
+	c, err := ioutil.ReadFile("./t/xcs-flight.igc")
+	if err != nil {
+		t.Fatalf("failed to load xcs flight :: %v", err)
+	}
+	content := string(c)
+	tests := []struct {
+		t string
+		c string
+		r Validity
+	}{
+		{"untouched file", content, Valid},
+		{"lf line endings", strings.Replace(content, "\r\n", "\n", -1), Valid},
+		{"changed pilot header", strings.Replace(content, "HOSITSITE:NOT SIGNED", "HOSITSITE:OTHER", 1), Valid},
+		{"changed pilot log", strings.Replace(content, "LPLTPILOT NOTE", "LPLTOTHER NOTE", 1), Valid},
+		{"changed fix", strings.Replace(content, "B1030104612050N", "B1030104612051N", 1), Invalid},
+		{"changed xcsoar log", strings.Replace(content, "LXCSSOME TEXT", "LXCSSOME TEXTS", 1), Invalid},
+		{"changed header", strings.Replace(content, "EZ PILOT", "EZ OTHER", 1), Invalid},
+		{"missing g record", content[:strings.Index(content, "\r\nG")+2], Invalid},
+	}
+	for _, test := range tests {
+		r, err := ValidateIGC(strings.NewReader(test.c))
+		if err != nil {
+			t.Errorf("%v failed :: %v", test.t, err)
+			continue
+		}
+		if r != test.r {
+			t.Errorf("%v failed :: expected %v got %v", test.t, test.r, r)
+		}
+	}
+}
+
+func TestXCSDigest(t *testing.T) {
+	data := []byte("AXCSAAA\r\nLXCS$*,!\\^~TEXT\r\n")
+	digest, err := xcsDigest(data)
+	if err != nil || len(digest) != 128 {
+		t.Fatalf("expected 128 chars digest got %v :: %v", digest, err)
+	}
+	// the third digest uses the standard md5 initial state, with control
+	// and reserved chars removed
+	expected := md5.Sum([]byte("AXCSAAALXCSTEXT"))
+	if digest[64:96] != hex.EncodeToString(expected[:]) {
+		t.Errorf("expected md5 %x got %v", expected, digest[64:96])
+	}
+}
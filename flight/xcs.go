@@ -0,0 +1,81 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package flight
+
+import (
+	"crypto/md5"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// xcsKeys holds the initial MD5 states of the four digests in the XCSoar
+// (XCS) G record.
+var xcsKeys = [4][4]uint32{
+	{0x1C80A301, 0x9EB30B89, 0x39CB2AFE, 0x0D0FEA76},
+	{0x48327203, 0x3948EBEA, 0x9A9B9C9E, 0xB3BED89A},
+	{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476},
+	{0xC8E899E8, 0x9321C28A, 0x438EBA12, 0x8CBE0AEE},
+}
+
+// XCSValidator validates the G records written by XCSoar, registered by
+// default for the XCS manufacturer.
+//
+// The signature is made of four MD5 digests of the signed data, each
+// starting from its own initial state, in hex. Only printable chars of the
+// records are digested, except the reserved ones ($*,!\^~).
+type XCSValidator struct{}
+
+// Validate implements Validator.Validate().
+func (v XCSValidator) Validate(data []byte, signature string) (bool, error) {
+	digest, err := xcsDigest(data)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(signature, digest), nil
+}
+
+// xcsDigest returns the XCS G record content for the given signed data.
+func xcsDigest(data []byte) (string, error) {
+	var filtered []byte
+	for _, c := range data {
+		if c >= 0x20 && c <= 0x7E && !strings.ContainsRune(`$*,!\^~`, rune(c)) {
+			filtered = append(filtered, c)
+		}
+	}
+	result := ""
+	for _, key := range xcsKeys {
+		h := md5.New()
+		// the md5 state is the magic, the state words, the block buffer
+		// and the message length
+		state := make([]byte, 4+16+md5.BlockSize+8)
+		copy(state, "md5\x01")
+		for i, k := range key {
+			binary.BigEndian.PutUint32(state[4+4*i:], k)
+		}
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			return "", err
+		}
+		h.Write(filtered)
+		result += hex.EncodeToString(h.Sum(nil))
+	}
+	return result, nil
+}
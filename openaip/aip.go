@@ -0,0 +1,244 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openaip
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/util"
+)
+
+// aipFile is the content of an AIP (XML) export file, of airspaces or
+// airports.
+type aipFile struct {
+	Airspaces []aipAirspace `xml:"AIRSPACES>ASP"`
+	Airports  []aipAirport  `xml:"WAYPOINTS>AIRPORT"`
+}
+
+type aipAirspace struct {
+	Category string   `xml:"CATEGORY,attr"`
+	ID       string   `xml:"ID"`
+	Country  string   `xml:"COUNTRY"`
+	Name     string   `xml:"NAME"`
+	Top      aipLimit `xml:"ALTLIMIT_TOP"`
+	Bottom   aipLimit `xml:"ALTLIMIT_BOTTOM"`
+	Polygon  string   `xml:"GEOMETRY>POLYGON"`
+}
+
+type aipLimit struct {
+	Reference string   `xml:"REFERENCE,attr"`
+	Alt       aipValue `xml:"ALT"`
+}
+
+type aipValue struct {
+	Unit  string `xml:"UNIT,attr"`
+	Value string `xml:",chardata"`
+}
+
+type aipAirport struct {
+	Type      string      `xml:"TYPE,attr"`
+	Country   string      `xml:"COUNTRY"`
+	Name      string      `xml:"NAME"`
+	ICAO      string      `xml:"ICAO"`
+	Latitude  string      `xml:"GEOLOCATION>LAT"`
+	Longitude string      `xml:"GEOLOCATION>LON"`
+	Elevation aipValue    `xml:"GEOLOCATION>ELEV"`
+	Radios    []aipRadio  `xml:"RADIO"`
+	Runways   []aipRunway `xml:"RWY"`
+}
+
+type aipRadio struct {
+	Category  string `xml:"CATEGORY,attr"`
+	Frequency string `xml:"FREQUENCY"`
+}
+
+type aipRunway struct {
+	Operations string   `xml:"OPERATIONS,attr"`
+	Name       string   `xml:"NAME"`
+	Surface    string   `xml:"SFC"`
+	Length     aipValue `xml:"LENGTH"`
+}
+
+// meters returns the value in meters, for the F (feet) and M units.
+func (v aipValue) meters() (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%v' :: %v", v.Value, err)
+	}
+	switch v.Unit {
+	case "F":
+		return f * util.Foot, nil
+	case "M", "":
+		return f, nil
+	}
+	return 0, fmt.Errorf("invalid unit '%v'", v.Unit)
+}
+
+// limit returns the limit in the format parsed by airspace.ParseLimit.
+func (l aipLimit) limit() (string, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(l.Alt.Value), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid limit '%v' :: %v", l.Alt.Value, err)
+	}
+	result := airspace.Limit{Value: v}
+	switch l.Alt.Unit {
+	case "F":
+		result.Unit = airspace.Feet
+	case "M":
+		result.Unit = airspace.Meters
+	case "FL":
+		result.Unit = airspace.FlightLevel
+	default:
+		return "", fmt.Errorf("invalid limit unit '%v'", l.Alt.Unit)
+	}
+	switch l.Reference {
+	case "MSL":
+		result.Reference = airspace.MSL
+	case "GND":
+		result.Reference = airspace.GND
+	case "STD":
+		result.Reference = airspace.STD
+	default:
+		return "", fmt.Errorf("invalid limit reference '%v'", l.Reference)
+	}
+	return result.String(), nil
+}
+
+// parseAIP decodes the AIP content in r.
+func parseAIP(r io.Reader) (aipFile, error) {
+	var f aipFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return f, fmt.Errorf("failed to parse aip content :: %v", err)
+	}
+	return f, nil
+}
+
+// parseAIPAirspace parses the airspaces in the AIP content in r, with the
+// given update time. Invalid airspaces are logged and skipped.
+func parseAIPAirspace(r io.Reader, update time.Time) ([]airspace.Airspace, error) {
+	f, err := parseAIP(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []airspace.Airspace
+	for _, asp := range f.Airspaces {
+		a, err := asp.airspace(update)
+		if err != nil {
+			glog.Warningf("skipping airspace :: %v", err)
+			continue
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// airspace converts the AIP airspace, with the given update time.
+func (asp aipAirspace) airspace(update time.Time) (airspace.Airspace, error) {
+	a := airspace.Airspace{ID: asp.ID, Name: strings.TrimSpace(asp.Name), Update: update}
+	if len(asp.Category) == 1 && asp.Category >= "A" && asp.Category <= "G" {
+		a.Class = asp.Category[0]
+	} else {
+		a.Type, a.Class = asp.Category, categoryClasses[asp.Category]
+	}
+	var err error
+	if a.Ceiling, err = asp.Top.limit(); err != nil {
+		return a, fmt.Errorf("invalid ceiling in airspace '%v' :: %v", a.Name, err)
+	}
+	if a.Floor, err = asp.Bottom.limit(); err != nil {
+		return a, fmt.Errorf("invalid floor in airspace '%v' :: %v", a.Name, err)
+	}
+	// points are given as 'lon lat, lon lat, ...'
+	var points [][]float64
+	for _, p := range strings.Split(asp.Polygon, ",") {
+		var lon, lat float64
+		if _, err := fmt.Sscan(p, &lon, &lat); err != nil {
+			return a, fmt.Errorf("invalid point '%v' in airspace '%v' :: %v", p, a.Name, err)
+		}
+		points = append(points, []float64{lon, lat})
+	}
+	if a.Segments, err = polygon(points); err != nil {
+		return a, fmt.Errorf("invalid geometry in airspace '%v' :: %v", a.Name, err)
+	}
+	return a, nil
+}
+
+// parseAIPAirfield parses the airports in the AIP content in r, with the
+// given update time.
+//
+// Runway details are taken from the first active runway, and the frequency
+// from the first communication radio.
+func parseAIPAirfield(r io.Reader, update time.Time) ([]airfield.Airfield, error) {
+	f, err := parseAIP(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []airfield.Airfield
+	for _, apt := range f.Airports {
+		a := airfield.Airfield{ID: apt.ICAO, ShortName: apt.ICAO, Name: strings.TrimSpace(apt.Name),
+			Region: apt.Country, ICAO: apt.ICAO, Flags: typeFlags[apt.Type], Update: update}
+		if a.ID == "" {
+			a.ID = a.Name
+		}
+		if a.Latitude, err = strconv.ParseFloat(strings.TrimSpace(apt.Latitude), 64); err != nil {
+			return nil, fmt.Errorf("invalid latitude in airport '%v' :: %v", a.Name, err)
+		}
+		if a.Longitude, err = strconv.ParseFloat(strings.TrimSpace(apt.Longitude), 64); err != nil {
+			return nil, fmt.Errorf("invalid longitude in airport '%v' :: %v", a.Name, err)
+		}
+		elevation, err := apt.Elevation.meters()
+		if err != nil {
+			return nil, fmt.Errorf("invalid elevation in airport '%v' :: %v", a.Name, err)
+		}
+		a.Elevation = round(elevation)
+		for _, radio := range apt.Radios {
+			if radio.Category == "COMMUNICATION" {
+				if a.Frequency, err = strconv.ParseFloat(strings.TrimSpace(radio.Frequency), 64); err != nil {
+					return nil, fmt.Errorf("invalid frequency in airport '%v' :: %v", a.Name, err)
+				}
+				break
+			}
+		}
+		for _, rwy := range apt.Runways {
+			if rwy.Operations != "ACTIVE" {
+				continue
+			}
+			if a.Runway, err = runway(rwy.Name); err != nil {
+				return nil, fmt.Errorf("invalid runway in airport '%v' :: %v", a.Name, err)
+			}
+			length, err := rwy.Length.meters()
+			if err != nil {
+				return nil, fmt.Errorf("invalid runway length in airport '%v' :: %v", a.Name, err)
+			}
+			a.Length = round(length)
+			a.Flags |= surfaceFlags[rwy.Surface]
+			break
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
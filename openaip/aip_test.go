@@ -0,0 +1,144 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openaip
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
+)
+
+var testUpdate = time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+
+var testAirspaces = []airspace.Airspace{
+	{ID: "150511", Class: 'D', Name: "CTR GENEVA", Ceiling: "4500ft AMSL", Floor: "GND",
+		Segments: []airspace.Segment{
+			{Type: airspace.Polygon, Coordinate1: "46:12:00 N 006:06:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "46:18:00 N 006:06:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "46:18:00 N 006:15:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "46:12:00 N 006:15:00 E"},
+		}},
+	{ID: "150600", Class: 'R', Type: "RESTRICTED", Name: "LS-R4 Schwyz", Ceiling: "FL150", Floor: "300m GND",
+		Segments: []airspace.Segment{
+			{Type: airspace.Polygon, Coordinate1: "47:00:00 N 008:36:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "47:03:00 N 008:42:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "46:57:00 N 008:45:00 E"},
+		}},
+}
+
+var testAirfields = []airfield.Airfield{
+	{ID: "LSTR", ShortName: "LSTR", Name: "MONTRICHER", Region: "CH", ICAO: "LSTR",
+		Flags: airfield.GliderSite | airfield.Grass, Length: 550, Elevation: 667, Runway: "1028",
+		Frequency: 122.475, Latitude: 46.59, Longitude: 6.4},
+	{ID: "LSGG", ShortName: "LSGG", Name: "GENEVA", Region: "CH", ICAO: "LSGG",
+		Flags: airfield.Concrete, Length: 3900, Elevation: 411, Runway: "0422",
+		Latitude: 46.2381, Longitude: 6.1089},
+}
+
+func TestParseAIPAirspace(t *testing.T) {
+	file, err := os.Open("t/ch_asp.aip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := parseAIPAirspace(file, testUpdate)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(result) != len(testAirspaces) {
+		t.Fatalf("expected %v airspaces got %v", len(testAirspaces), len(result))
+	}
+	for i, a := range result {
+		e := testAirspaces[i]
+		e.Update = testUpdate
+		if !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %+v got %+v", e, a)
+		}
+		if _, err := a.CeilingLimit(); err != nil {
+			t.Errorf("failed to parse ceiling '%v' :: %v", a.Ceiling, err)
+		}
+		if _, err := a.FloorLimit(); err != nil {
+			t.Errorf("failed to parse floor '%v' :: %v", a.Floor, err)
+		}
+	}
+}
+
+func TestParseAIPAirfield(t *testing.T) {
+	file, err := os.Open("t/ch_wpt.aip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := parseAIPAirfield(file, testUpdate)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(result) != len(testAirfields) {
+		t.Fatalf("expected %v airfields got %v", len(testAirfields), len(result))
+	}
+	for i, a := range result {
+		e := testAirfields[i]
+		e.Update = testUpdate
+		if !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %+v got %+v", e, a)
+		}
+	}
+}
+
+func TestParseAIPInvalid(t *testing.T) {
+	limits := `<ALTLIMIT_TOP REFERENCE="MSL"><ALT UNIT="F">4500</ALT></ALTLIMIT_TOP>` +
+		`<ALTLIMIT_BOTTOM REFERENCE="GND"><ALT UNIT="F">0</ALT></ALTLIMIT_BOTTOM>`
+	if _, err := parseAIPAirspace(strings.NewReader(`<OPENAIP><AIRSPACES><ASP CATEGORY="D">`), testUpdate); err == nil {
+		t.Errorf("expected error parsing truncated content but got success")
+	}
+	// invalid airspaces are skipped, the valid one after is kept
+	valid := `<ASP CATEGORY="D"><NAME>VALID</NAME>` + limits + `<GEOMETRY><POLYGON>6.1 46.2, 6.1 46.3, 6.25 46.3, 6.1 46.2</POLYGON></GEOMETRY></ASP>`
+	airspaces := []string{
+		`<ASP CATEGORY="D"><ALTLIMIT_TOP REFERENCE="MSL"><ALT UNIT="YD">1</ALT></ALTLIMIT_TOP></ASP>`,
+		`<ASP CATEGORY="D"><ALTLIMIT_TOP REFERENCE="AGL"><ALT UNIT="F">1</ALT></ALTLIMIT_TOP></ASP>`,
+		`<ASP CATEGORY="D"><ALTLIMIT_TOP REFERENCE="MSL"><ALT UNIT="F">high</ALT></ALTLIMIT_TOP></ASP>`,
+		`<ASP CATEGORY="D">` + limits + `<GEOMETRY><POLYGON>6.1 46.2, 6.1</POLYGON></GEOMETRY></ASP>`,
+	}
+	for _, test := range airspaces {
+		content := `<OPENAIP><AIRSPACES>` + test + valid + `</AIRSPACES></OPENAIP>`
+		result, err := parseAIPAirspace(strings.NewReader(content), testUpdate)
+		if err != nil || len(result) != 1 || result[0].Name != "VALID" {
+			t.Errorf("expected only the valid airspace parsing '%v' got %+v :: %v", test, result, err)
+		}
+	}
+	airports := []string{
+		`<OPENAIP><WAYPOINTS><AIRPORT><GEOLOCATION><LAT>north</LAT></GEOLOCATION></AIRPORT></WAYPOINTS></OPENAIP>`,
+		`<OPENAIP><WAYPOINTS><AIRPORT><GEOLOCATION><LAT>46</LAT><LON>6</LON><ELEV UNIT="M">high</ELEV></GEOLOCATION></AIRPORT></WAYPOINTS></OPENAIP>`,
+		`<OPENAIP><WAYPOINTS><AIRPORT><GEOLOCATION><LAT>46</LAT><LON>6</LON><ELEV UNIT="M">600</ELEV></GEOLOCATION>` +
+			`<RWY OPERATIONS="ACTIVE"><NAME>40/22</NAME></RWY></AIRPORT></WAYPOINTS></OPENAIP>`,
+		`<OPENAIP><WAYPOINTS><AIRPORT><GEOLOCATION><LAT>46</LAT><LON>6</LON><ELEV UNIT="M">600</ELEV></GEOLOCATION>` +
+			`<RADIO CATEGORY="COMMUNICATION"><FREQUENCY>122,5</FREQUENCY></RADIO></AIRPORT></WAYPOINTS></OPENAIP>`,
+	}
+	for _, test := range airports {
+		if _, err := parseAIPAirfield(strings.NewReader(test), testUpdate); err == nil {
+			t.Errorf("expected error parsing '%v' but got success", test)
+		}
+	}
+}
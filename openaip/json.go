@@ -0,0 +1,283 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openaip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/util"
+)
+
+// jsonTypes maps the JSON airspace types to the AIP categories, 0 (other)
+// having none.
+var jsonTypes = map[int]string{
+	1: "RESTRICTED", 2: "DANGER", 3: "PROHIBITED", 4: "CTR", 5: "TMZ",
+	6: "RMZ", 7: "TMA", 8: "TRA", 9: "TSA", 10: "FIR", 11: "UIR", 12: "ADIZ",
+	13: "ATZ", 14: "MATZ", 15: "AIRWAY", 16: "MTR", 17: "ALERT", 18: "WARNING",
+	19: "PROTECTED", 20: "HTZ", 21: "GLIDING",
+}
+
+// jsonSurfaces maps the JSON runway main surface composites to the AIP
+// surfaces.
+var jsonSurfaces = map[int]string{
+	0: "ASPH", 1: "CONC", 2: "GRAS", 3: "SAND", 5: "ASPH", 10: "CLAY", 12: "GRVL", 13: "UNPV",
+}
+
+// jsonAirportTypes maps the JSON airport types to the AIP types.
+var jsonAirportTypes = map[int]string{
+	1: "GLIDING", 6: "LIGHT_AIRCRAFT",
+}
+
+// JSON units and reference datums of values.
+const (
+	jsonMeters      = 0
+	jsonFeet        = 1
+	jsonFlightLevel = 6
+
+	jsonGND = 0
+	jsonMSL = 1
+	jsonSTD = 2
+)
+
+type jsonValue struct {
+	Value          float64 `json:"value"`
+	Unit           int     `json:"unit"`
+	ReferenceDatum int     `json:"referenceDatum"`
+}
+
+type jsonGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type jsonAirspace struct {
+	ID         string       `json:"_id"`
+	Name       string       `json:"name"`
+	Type       int          `json:"type"`
+	ICAOClass  int          `json:"icaoClass"`
+	Country    string       `json:"country"`
+	Geometry   jsonGeometry `json:"geometry"`
+	UpperLimit jsonValue    `json:"upperLimit"`
+	LowerLimit jsonValue    `json:"lowerLimit"`
+	UpdatedAt  string       `json:"updatedAt"`
+}
+
+type jsonAirport struct {
+	ID          string       `json:"_id"`
+	Name        string       `json:"name"`
+	ICAOCode    string       `json:"icaoCode"`
+	Type        int          `json:"type"`
+	Country     string       `json:"country"`
+	Geometry    jsonGeometry `json:"geometry"`
+	Elevation   jsonValue    `json:"elevation"`
+	Frequencies []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+	} `json:"frequencies"`
+	Runways []struct {
+		Designator string `json:"designator"`
+		MainRunway bool   `json:"mainRunway"`
+		Surface    struct {
+			MainComposite int `json:"mainComposite"`
+		} `json:"surface"`
+		Dimension struct {
+			Length jsonValue `json:"length"`
+		} `json:"dimension"`
+	} `json:"runways"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// meters returns the value in meters, for the meters and feet units.
+func (v jsonValue) meters() (float64, error) {
+	switch v.Unit {
+	case jsonMeters:
+		return v.Value, nil
+	case jsonFeet:
+		return v.Value * util.Foot, nil
+	}
+	return 0, fmt.Errorf("invalid unit %v", v.Unit)
+}
+
+// limit returns the limit in the format parsed by airspace.ParseLimit.
+func (v jsonValue) limit() (string, error) {
+	result := airspace.Limit{Value: v.Value}
+	switch v.Unit {
+	case jsonMeters:
+		result.Unit = airspace.Meters
+	case jsonFeet:
+		result.Unit = airspace.Feet
+	case jsonFlightLevel:
+		result.Unit = airspace.FlightLevel
+	default:
+		return "", fmt.Errorf("invalid limit unit %v", v.Unit)
+	}
+	switch v.ReferenceDatum {
+	case jsonGND:
+		result.Reference = airspace.GND
+	case jsonMSL:
+		result.Reference = airspace.MSL
+	case jsonSTD:
+		result.Reference = airspace.STD
+	default:
+		return "", fmt.Errorf("invalid limit reference %v", v.ReferenceDatum)
+	}
+	return result.String(), nil
+}
+
+// decodeJSON decodes the JSON content in r into items, accepting both a list
+// of items and an object with the list in 'items'.
+func decodeJSON(r io.Reader, items interface{}) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && content[0] == '{' {
+		var wrapper struct {
+			Items json.RawMessage `json:"items"`
+		}
+		if err = json.Unmarshal(content, &wrapper); err != nil {
+			return fmt.Errorf("failed to parse json content :: %v", err)
+		}
+		content = wrapper.Items
+	}
+	if err = json.Unmarshal(content, items); err != nil {
+		return fmt.Errorf("failed to parse json content :: %v", err)
+	}
+	return nil
+}
+
+// parseJSONAirspace parses the airspaces in the JSON content in r. Invalid
+// airspaces are logged and skipped.
+func parseJSONAirspace(r io.Reader) ([]airspace.Airspace, error) {
+	var items []jsonAirspace
+	if err := decodeJSON(r, &items); err != nil {
+		return nil, err
+	}
+	var result []airspace.Airspace
+	for _, asp := range items {
+		a, err := asp.airspace()
+		if err != nil {
+			glog.Warningf("skipping airspace :: %v", err)
+			continue
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// airspace converts the JSON airspace.
+func (asp jsonAirspace) airspace() (airspace.Airspace, error) {
+	a := airspace.Airspace{ID: asp.ID, Name: strings.TrimSpace(asp.Name), Type: jsonTypes[asp.Type]}
+	if asp.ICAOClass >= 0 && asp.ICAOClass <= 6 {
+		a.Class = byte('A' + asp.ICAOClass)
+	} else {
+		a.Class = categoryClasses[a.Type]
+	}
+	var err error
+	if a.Update, err = parseTime(asp.UpdatedAt); err != nil {
+		return a, fmt.Errorf("invalid update in airspace '%v' :: %v", a.Name, err)
+	}
+	if a.Ceiling, err = asp.UpperLimit.limit(); err != nil {
+		return a, fmt.Errorf("invalid ceiling in airspace '%v' :: %v", a.Name, err)
+	}
+	if a.Floor, err = asp.LowerLimit.limit(); err != nil {
+		return a, fmt.Errorf("invalid floor in airspace '%v' :: %v", a.Name, err)
+	}
+	var rings [][][]float64
+	if asp.Geometry.Type != "Polygon" || json.Unmarshal(asp.Geometry.Coordinates, &rings) != nil || len(rings) == 0 {
+		return a, fmt.Errorf("invalid geometry in airspace '%v'", a.Name)
+	}
+	if a.Segments, err = polygon(rings[0]); err != nil {
+		return a, fmt.Errorf("invalid geometry in airspace '%v' :: %v", a.Name, err)
+	}
+	return a, nil
+}
+
+// parseJSONAirfield parses the airports in the JSON content in r.
+//
+// Runway details are taken from the main runway, or the first one, and the
+// frequency from the primary one, or the first one.
+func parseJSONAirfield(r io.Reader) ([]airfield.Airfield, error) {
+	var items []jsonAirport
+	if err := decodeJSON(r, &items); err != nil {
+		return nil, err
+	}
+	var result []airfield.Airfield
+	for _, apt := range items {
+		a := airfield.Airfield{ID: apt.ICAOCode, ShortName: apt.ICAOCode, Name: strings.TrimSpace(apt.Name),
+			Region: apt.Country, ICAO: apt.ICAOCode, Flags: typeFlags[jsonAirportTypes[apt.Type]]}
+		if a.ID == "" {
+			a.ID = a.Name
+		}
+		var err error
+		if a.Update, err = parseTime(apt.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("invalid update in airport '%v' :: %v", a.Name, err)
+		}
+		var point []float64
+		if apt.Geometry.Type != "Point" || json.Unmarshal(apt.Geometry.Coordinates, &point) != nil || len(point) < 2 {
+			return nil, fmt.Errorf("invalid geometry in airport '%v'", a.Name)
+		}
+		a.Longitude, a.Latitude = point[0], point[1]
+		elevation, err := apt.Elevation.meters()
+		if err != nil {
+			return nil, fmt.Errorf("invalid elevation in airport '%v' :: %v", a.Name, err)
+		}
+		a.Elevation = round(elevation)
+		for i, f := range apt.Frequencies {
+			if i == 0 || f.Primary {
+				if a.Frequency, err = strconv.ParseFloat(strings.TrimSpace(f.Value), 64); err != nil {
+					return nil, fmt.Errorf("invalid frequency in airport '%v' :: %v", a.Name, err)
+				}
+			}
+			if f.Primary {
+				break
+			}
+		}
+		for i, rwy := range apt.Runways {
+			if i > 0 && !rwy.MainRunway {
+				continue
+			}
+			if a.Runway, err = runway(rwy.Designator); err != nil {
+				return nil, fmt.Errorf("invalid runway in airport '%v' :: %v", a.Name, err)
+			}
+			length, err := rwy.Dimension.Length.meters()
+			if err != nil {
+				return nil, fmt.Errorf("invalid runway length in airport '%v' :: %v", a.Name, err)
+			}
+			a.Length = round(length)
+			a.Flags = typeFlags[jsonAirportTypes[apt.Type]] | surfaceFlags[jsonSurfaces[rwy.Surface.MainComposite]]
+			if rwy.MainRunway {
+				break
+			}
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openaip
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJSONAirspace(t *testing.T) {
+	file, err := os.Open("t/ch_asp.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := parseJSONAirspace(file)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(result) != len(testAirspaces) {
+		t.Fatalf("expected %v airspaces got %v", len(testAirspaces), len(result))
+	}
+	ids := []string{"62614a3f1cd8bcc1a0c94c5e", "62614a3f1cd8bcc1a0c94c8a"}
+	for i, a := range result {
+		e := testAirspaces[i]
+		e.ID, e.Update = ids[i], time.Date(2015, 6, 10*(i+1), 12, 0, 0, 0, time.UTC)
+		if e.Type == "" {
+			e.Type = "CTR"
+		}
+		if !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %+v got %+v", e, a)
+		}
+	}
+}
+
+func TestParseJSONAirfield(t *testing.T) {
+	file, err := os.Open("t/ch_apt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	result, err := parseJSONAirfield(file)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(result) != len(testAirfields) {
+		t.Fatalf("expected %v airfields got %v", len(testAirfields), len(result))
+	}
+	runways := []string{"2810", "0422"}
+	frequencies := []float64{122.475, 118.7}
+	for i, a := range result {
+		e := testAirfields[i]
+		e.Update = time.Date(2015, 6, 10*(i+1), 12, 0, 0, 0, time.UTC)
+		e.Runway, e.Frequency = runways[i], frequencies[i]
+		if !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %+v got %+v", e, a)
+		}
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	limits := `"upperLimit": {"value": 4500, "unit": 1, "referenceDatum": 1}, "lowerLimit": {"value": 0, "unit": 1, "referenceDatum": 0}`
+	for _, test := range []string{`[{"name": "A"`, `{"items": {"name": "A"}}`} {
+		if _, err := parseJSONAirspace(strings.NewReader(test)); err == nil {
+			t.Errorf("expected error parsing '%v' but got success", test)
+		}
+	}
+	// invalid airspaces are skipped, the valid one after is kept
+	valid := `{"name": "VALID", ` + limits +
+		`, "geometry": {"type": "Polygon", "coordinates": [[[6.1, 46.2], [6.1, 46.3], [6.25, 46.3], [6.1, 46.2]]]}}`
+	airspaces := []string{
+		`{"name": "A", "updatedAt": "yesterday"}`,
+		`{"name": "A", "upperLimit": {"value": 1, "unit": 3}}`,
+		`{"name": "A", "upperLimit": {"value": 1, "unit": 1, "referenceDatum": 5}}`,
+		`{"name": "A", ` + limits + `, "geometry": {"type": "Point", "coordinates": [6.1, 46.2]}}`,
+		`{"name": "A", ` + limits + `, "geometry": {"type": "Polygon", "coordinates": [[[6.1, 46.2], [6.1]]]}}`,
+	}
+	for _, test := range airspaces {
+		result, err := parseJSONAirspace(strings.NewReader("[" + test + ", " + valid + "]"))
+		if err != nil || len(result) != 1 || result[0].Name != "VALID" {
+			t.Errorf("expected only the valid airspace parsing '%v' got %+v :: %v", test, result, err)
+		}
+	}
+	point := `"geometry": {"type": "Point", "coordinates": [6.4, 46.59]}`
+	airports := []string{
+		`[{"name": "A", "geometry": {"type": "Point", "coordinates": [6.4]}}]`,
+		`[{"name": "A", ` + point + `, "elevation": {"value": 1, "unit": 6}}]`,
+		`[{"name": "A", ` + point + `, "frequencies": [{"value": "high"}]}]`,
+		`[{"name": "A", ` + point + `, "runways": [{"designator": "XX"}]}]`,
+		`[{"name": "A", ` + point + `, "runways": [{"designator": "10", "dimension": {"length": {"value": 1, "unit": 6}}}]}]`,
+	}
+	for _, test := range airports {
+		if _, err := parseJSONAirfield(strings.NewReader(test)); err == nil {
+			t.Errorf("expected error parsing '%v' but got success", test)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package openaip provides functionality to read airspace and airport
+// information from OpenAIP export files.
+//
+// Check the OpenAIP website for more information on the data:
+//
+//	http://www.openaip.net
+//
+// Both the AIP (XML) and JSON export formats are supported. Files are
+// expected at the configured base location, one per region and type, named
+// like ch_asp.aip and ch_wpt.aip, or ch_asp.json and ch_apt.json.
+package openaip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// ID for this plugin implementation.
+const (
+	ID string = "openaip"
+)
+
+// Regions used when none are given, as country codes.
+var Regions = []string{"AL", "AT", "BA", "BE", "BG", "CH", "CY", "CZ", "DE",
+	"DK", "EE", "ES", "FI", "FR", "GB", "GR", "HR", "HU", "IE", "IS", "IT",
+	"LT", "LU", "LV", "ME", "MK", "MT", "NL", "NO", "PL", "PT", "RO", "RS",
+	"SE", "SI", "SK", "AU", "BR", "CA", "NZ", "US", "ZA"}
+
+// Supported values for Config.Format.
+const (
+	AIP  = "aip"
+	JSON = "json"
+)
+
+// Config holds all config information for the openaip plugin.
+type Config struct {
+	// BaseURL is the path or http url where the export files are.
+	BaseURL string
+	// Format is the format of the export files, aip (default) or json.
+	Format string
+}
+
+// OpenAIP is the plugin implementation for OpenAIP export files.
+type OpenAIP struct {
+	Config
+}
+
+// New returns a new OpenAIP instance.
+func New(cfg Config) (*OpenAIP, error) {
+	if cfg.Format == "" {
+		cfg.Format = AIP
+	}
+	op := OpenAIP{Config: cfg}
+	glog.V(20).Infof("Plugin openaip initialized :: %+v", op)
+	return &op, nil
+}
+
+// GetAirspace follows airspace.GetAirspace().
+func (op *OpenAIP) GetAirspace(regions []string, updatedSince time.Time) ([]airspace.Airspace, error) {
+	var result []airspace.Airspace
+	err := op.each(regions, "asp", "asp", func(r io.Reader, modified time.Time) error {
+		var airspaces []airspace.Airspace
+		var err error
+		if op.Format == JSON {
+			airspaces, err = parseJSONAirspace(r)
+		} else {
+			airspaces, err = parseAIPAirspace(r, modified)
+		}
+		for _, a := range airspaces {
+			if a.Update.IsZero() || a.Update.After(updatedSince) {
+				result = append(result, a)
+			}
+		}
+		return err
+	})
+	return result, err
+}
+
+// PutAirspace follows airspace.PutAirspace().
+func (op *OpenAIP) PutAirspace(airspaces []airspace.Airspace) error {
+	return errors.New("not available for openaip plugin")
+}
+
+// GetAirfield follows airfield.GetAirfield().
+func (op *OpenAIP) GetAirfield(regions []string, updatedSince time.Time) ([]airfield.Airfield, error) {
+	var result []airfield.Airfield
+	err := op.each(regions, "wpt", "apt", func(r io.Reader, modified time.Time) error {
+		var airfields []airfield.Airfield
+		var err error
+		if op.Format == JSON {
+			airfields, err = parseJSONAirfield(r)
+		} else {
+			airfields, err = parseAIPAirfield(r, modified)
+		}
+		for _, a := range airfields {
+			if a.Update.IsZero() || a.Update.After(updatedSince) {
+				result = append(result, a)
+			}
+		}
+		return err
+	})
+	return result, err
+}
+
+// PutAirfield follows airfield.PutAirfield().
+func (op *OpenAIP) PutAirfield(airfields []airfield.Airfield) error {
+	return errors.New("not available for openaip plugin")
+}
+
+// each calls parse with the content of the export file of each region, named
+// with the given aip or json suffix. The file modification time is passed to
+// parse, zero if not known. Nil regions means all Regions.
+func (op *OpenAIP) each(regions []string, aip string, json string,
+	parse func(r io.Reader, modified time.Time) error) error {
+	if regions == nil {
+		regions = Regions
+	}
+	suffix := aip + "." + AIP
+	if op.Format == JSON {
+		suffix = json + "." + JSON
+	} else if op.Format != AIP {
+		return fmt.Errorf("unsupported format :: %v", op.Format)
+	}
+	for _, region := range regions {
+		if region == "" {
+			return errors.New("empty region given")
+		}
+		location := op.BaseURL + "/" + strings.ToLower(region) + "_" + suffix
+		if err := fetch(location, parse); err != nil {
+			return fmt.Errorf("failed to get %v :: %v", location, err)
+		}
+	}
+	return nil
+}
+
+// fetch calls parse with the content at the given location, either an http
+// url or a local path.
+func fetch(location string, parse func(r io.Reader, modified time.Time) error) error {
+	resp, err := http.Get(location)
+	if err == nil { // case http
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+		modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		return parse(resp.Body, modified)
+	}
+	// case file
+	file, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var modified time.Time
+	if info, err := file.Stat(); err == nil {
+		modified = info.ModTime()
+	}
+	return parse(file, modified)
+}
+
+// categoryClasses maps the OpenAIP categories with no ICAO class to the
+// matching OpenAir classes.
+var categoryClasses = map[string]byte{
+	"RESTRICTED": 'R',
+	"DANGER":     'Q',
+	"PROHIBITED": 'P',
+	"GLIDING":    'W',
+	"WAVE":       'W',
+}
+
+// surfaceFlags maps the OpenAIP runway surfaces to airfield flags.
+var surfaceFlags = map[string]int{
+	"ASPH": airfield.Asphalt,
+	"CONC": airfield.Concrete,
+	"GRAS": airfield.Grass,
+	"SAND": airfield.Sand,
+	"CLAY": airfield.Clay,
+	"GRVL": airfield.Gravel,
+	"UNPV": airfield.Dirt,
+}
+
+// typeFlags maps the OpenAIP airport types to airfield flags.
+var typeFlags = map[string]int{
+	"GLIDING":        airfield.GliderSite,
+	"LIGHT_AIRCRAFT": airfield.ULMSite,
+}
+
+// polygon returns the polygon segments for the given lon/lat points,
+// skipping the last one if it closes the polygon.
+func polygon(points [][]float64) ([]airspace.Segment, error) {
+	var result []airspace.Segment
+	for i, p := range points {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("invalid point :: %v", p)
+		}
+		if i == len(points)-1 && i > 0 && p[0] == points[0][0] && p[1] == points[0][1] {
+			break
+		}
+		result = append(result, airspace.Segment{Type: airspace.Polygon,
+			Coordinate1: spatial.Coordinate{Latitude: p[1], Longitude: p[0]}.String()})
+	}
+	return result, nil
+}
+
+// runway returns the runway designators (like 1129) for the given runway
+// name, like 11/29, 11L/29R or 11.
+func runway(name string) (string, error) {
+	parts := strings.SplitN(name, "/", 2)
+	first, err := designator(parts[0])
+	if err != nil {
+		return "", err
+	}
+	second := 0
+	if len(parts) > 1 {
+		if second, err = designator(parts[1]); err != nil {
+			return "", err
+		}
+	}
+	return airfield.RunwayName(first, second), nil
+}
+
+// designator returns the number of a runway designator, like 11 or 29R.
+func designator(s string) (int, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "LRC")
+	d, err := strconv.Atoi(s)
+	if err != nil || d < 1 || d > 36 {
+		return 0, fmt.Errorf("invalid runway designator '%v'", s)
+	}
+	return d, nil
+}
+
+// round returns v rounded to the nearest integer.
+func round(v float64) int {
+	return int(math.Floor(v + 0.5))
+}
+
+// parseTime parses the given RFC3339 time, with empty values as zero.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openaip
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetAirspace(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		regions []string
+		since   time.Time
+		n       int
+	}{
+		{Config{BaseURL: "t"}, []string{"CH"}, time.Time{}, 2},
+		{Config{BaseURL: "t"}, []string{"ch"}, time.Now().Add(time.Hour), 0},
+		{Config{BaseURL: "t", Format: JSON}, []string{"CH"}, time.Time{}, 2},
+		{Config{BaseURL: "t", Format: JSON}, []string{"CH"}, time.Date(2015, 6, 15, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, test := range tests {
+		op, _ := New(test.cfg)
+		airspaces, err := op.GetAirspace(test.regions, test.since)
+		if err != nil {
+			t.Errorf("failed to get airspace :: %v", err)
+			continue
+		}
+		if len(airspaces) != test.n {
+			t.Errorf("expected %v airspaces for %+v got %v", test.n, test, len(airspaces))
+		}
+	}
+}
+
+func TestGetAirfield(t *testing.T) {
+	tests := []struct {
+		cfg   Config
+		since time.Time
+		n     int
+	}{
+		{Config{BaseURL: "t"}, time.Time{}, 2},
+		{Config{BaseURL: "t", Format: JSON}, time.Time{}, 2},
+		{Config{BaseURL: "t", Format: JSON}, time.Date(2015, 6, 15, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, test := range tests {
+		op, _ := New(test.cfg)
+		airfields, err := op.GetAirfield([]string{"CH"}, test.since)
+		if err != nil {
+			t.Errorf("failed to get airfields :: %v", err)
+			continue
+		}
+		if len(airfields) != test.n {
+			t.Errorf("expected %v airfields for %+v got %v", test.n, test, len(airfields))
+		}
+	}
+}
+
+func TestGetHTTP(t *testing.T) {
+	modified := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadFile("t" + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	op, _ := New(Config{BaseURL: ts.URL})
+	airspaces, err := op.GetAirspace([]string{"CH"}, time.Time{})
+	if err != nil || len(airspaces) != 2 {
+		t.Fatalf("expected 2 airspaces got %v :: %v", len(airspaces), err)
+	}
+	if !airspaces[0].Update.Equal(modified) {
+		t.Errorf("expected update %v got %v", modified, airspaces[0].Update)
+	}
+	airspaces, err = op.GetAirspace([]string{"CH"}, modified)
+	if err != nil || len(airspaces) != 0 {
+		t.Errorf("expected no airspaces got %v :: %v", len(airspaces), err)
+	}
+	if _, err := op.GetAirfield([]string{"FI"}, time.Time{}); err == nil {
+		t.Errorf("expected error for missing region but got success")
+	}
+}
+
+func TestGetInvalid(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		regions []string
+	}{
+		{Config{BaseURL: "t"}, []string{""}},
+		{Config{BaseURL: "t"}, []string{"PT"}},
+		{Config{BaseURL: "t", Format: "csv"}, []string{"CH"}},
+	}
+	for _, test := range tests {
+		op, _ := New(test.cfg)
+		if _, err := op.GetAirspace(test.regions, time.Time{}); err == nil {
+			t.Errorf("expected error getting airspace for %+v but got success", test)
+		}
+		if _, err := op.GetAirfield(test.regions, time.Time{}); err == nil {
+			t.Errorf("expected error getting airfields for %+v but got success", test)
+		}
+	}
+}
+
+func TestGetRegions(t *testing.T) {
+	op, _ := New(Config{BaseURL: "t"})
+	airspaces, err := op.GetAirspace([]string{}, time.Time{})
+	if err != nil || len(airspaces) != 0 {
+		t.Errorf("expected no airspaces for empty regions got %v :: %v", len(airspaces), err)
+	}
+	airfields, err := op.GetAirfield([]string{}, time.Time{})
+	if err != nil || len(airfields) != 0 {
+		t.Errorf("expected no airfields for empty regions got %v :: %v", len(airfields), err)
+	}
+
+	defer func(regions []string) { Regions = regions }(Regions)
+	Regions = []string{"CH"}
+	airspaces, err = op.GetAirspace(nil, time.Time{})
+	if err != nil || len(airspaces) != 2 {
+		t.Errorf("expected 2 airspaces for all regions got %v :: %v", len(airspaces), err)
+	}
+	airfields, err = op.GetAirfield(nil, time.Time{})
+	if err != nil || len(airfields) != 2 {
+		t.Errorf("expected 2 airfields for all regions got %v :: %v", len(airfields), err)
+	}
+}
+
+func TestPut(t *testing.T) {
+	op, _ := New(Config{})
+	if op.PutAirspace(nil) == nil || op.PutAirfield(nil) == nil {
+		t.Errorf("expected put to fail but got success")
+	}
+}
+
+func TestRunway(t *testing.T) {
+	tests := map[string]string{"10/28": "1028", "04L/22R": "0422", "28": "2810", "36": "3618", "18": "1836"}
+	for name, e := range tests {
+		if r, err := runway(name); err != nil || r != e {
+			t.Errorf("expected %v for '%v' got %v :: %v", e, name, r, err)
+		}
+	}
+	for _, name := range []string{"", "40", "10/XX", "H1"} {
+		if _, err := runway(name); err == nil {
+			t.Errorf("expected error for '%v' but got success", name)
+		}
+	}
+}
@@ -26,52 +26,96 @@
 package openair
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"image/color"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
 	"github.com/rochaporto/ezgliding/airspace"
 )
 
-// Local temporary storage for airspace pen/brush types
-// Key is the airspace class, value in the Pen object
-var airspacePens = map[byte]airspace.Pen{}
-
 // Fetch gets and returns the airspace definitions at the given location
 // Both http URIs and local (relative or absolute) paths are supported.
 func Fetch(location string) ([]airspace.Airspace, error) {
-	var content []byte
-
 	resp, err := http.Get(location)
 	// case http
 	if err == nil {
 		defer resp.Body.Close()
-		content, err = ioutil.ReadAll(resp.Body)
-	} else { // case file
-		content, err = ioutil.ReadFile(location)
-		if err != nil {
-			return nil, err
-		}
+		return NewParser().Parse(resp.Body)
 	}
-	return Parse(content)
+	// case file
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewParser().Parse(file)
 }
 
 // Parse parses the content given, retrieving the corresponding array
 // of Airspace objects.
 func Parse(content []byte) ([]airspace.Airspace, error) {
-	items := strings.Split(string(content), "*\n")
+	return NewParser().Parse(bytes.NewReader(content))
+}
+
+// Parser parses OpenAir content, keeping the pen/brush state set in it.
+//
+// Pens apply to the airspaces of the same class that follow in the same
+// content, each call to Parse starts with no pens set. A Parser must not be
+// used concurrently, separate instances can.
+type Parser struct {
+	// Key is the airspace class, value in the Pen object
+	pens map[byte]airspace.Pen
+}
+
+// NewParser returns a new Parser, with no pens set.
+func NewParser() *Parser {
+	return &Parser{pens: map[byte]airspace.Pen{}}
+}
+
+// Parse parses the content in r, retrieving the corresponding array of
+// Airspace objects.
+//
+// Each airspace starts with an AC line. Lines starting with '*' are comments,
+// and both LF and CRLF line endings are supported.
+func (p *Parser) Parse(r io.Reader) ([]airspace.Airspace, error) {
+	p.pens = map[byte]airspace.Pen{}
 	result := []airspace.Airspace{}
-	for i := range items {
-		airspace, found, err := parseSingle([]byte(items[i]))
+	var lines []string
+	// number of the first line in lines, starting at 1
+	first, n := 1, 0
+	flush := func() error {
+		airspace, found, err := p.parseSingle(lines, first)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if found {
 			result = append(result, airspace)
 		}
+		lines, first = nil, n
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.SplitN(strings.Trim(line, " "), " ", 2)[0] == "AC" && len(lines) > 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -91,27 +135,47 @@ func styleToAirspace(value int) airspace.PenStyle {
 	}
 }
 
-// parseSingle expects a single airspace definition in the content object,
-// returning the corresponding Airspace object.
+// colorToAirspace converts the given brush components to a color, with
+// -1 values meaning transparent.
+func colorToAirspace(r, g, b int) color.Color {
+	if r < 0 || g < 0 || b < 0 {
+		return color.RGBA64{}
+	}
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: 1.0}
+}
+
+// parseSingle expects the lines of a single airspace definition, returning
+// the corresponding Airspace object. The first line has the given number in
+// the content, used in errors.
 // It is usually called by Parse.
-func parseSingle(content []byte) (airspace.Airspace, bool, error) {
+//
+// Lines with unrecognized keys are kept in Airspace.Extra.
+func (p *Parser) parseSingle(lines []string, first int) (airspace.Airspace, bool, error) {
 	var aspace airspace.Airspace
 	var x string
-	var clockwise bool
+	// arcs are clockwise unless set otherwise with V D=-
+	clockwise := true
+	var width float64
 	found := false
 
-	lines := strings.Split(string(content), "\n")
 	for i := range lines {
 		line := strings.Trim(lines[i], " ")
+		n := first + i
 		if len(line) == 0 || line[0] == '*' { // comment or empty
 			continue
 		}
 		elems := strings.SplitN(line, " ", 2)
-		key, value := elems[0], elems[1]
+		key, value := elems[0], ""
+		if len(elems) > 1 {
+			value = strings.Trim(elems[1], " ")
+		}
 		switch key {
 		case "AC":
+			if value == "" {
+				return airspace.Airspace{}, false, fmt.Errorf("Missing class in line %v '%v'", n, line)
+			}
 			aspace.Class = value[0]
-			aspace.Pen = airspacePens[aspace.Class]
+			aspace.Pen = p.pens[aspace.Class]
 		case "AN":
 			found = true
 			aspace.Name = strings.Trim(value, " ")
@@ -119,8 +183,25 @@ func parseSingle(content []byte) (airspace.Airspace, bool, error) {
 			aspace.Floor = value
 		case "AH":
 			aspace.Ceiling = value
+		case "AY":
+			aspace.Type = value
+		case "AF":
+			frequency, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid frequency in line %v '%v' :: %v", n, line, err)
+			}
+			aspace.Frequency = frequency
+		case "AG":
+			aspace.Station = value
+		case "AA":
+			aspace.Activation = append(aspace.Activation, value)
+		case "AT":
+			aspace.Label = append(aspace.Label, value)
 		case "DA":
 			values := strings.Split(value, ",")
+			if len(values) != 3 {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid arc in line %v '%v'", n, line)
+			}
 			angleStart, _ := strconv.ParseFloat(values[1], 64)
 			angleEnd, _ := strconv.ParseFloat(values[2], 64)
 			radius, _ := strconv.ParseFloat(values[0], 64)
@@ -129,6 +210,9 @@ func parseSingle(content []byte) (airspace.Airspace, bool, error) {
 					Radius: radius, AngleStart: angleStart, AngleEnd: angleEnd})
 		case "DB":
 			values := strings.Split(value, ",")
+			if len(values) != 2 {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid arc in line %v '%v'", n, line)
+			}
 			aspace.Segments = append(aspace.Segments,
 				airspace.Segment{Type: airspace.Arc, X: x, Clockwise: clockwise,
 					Coordinate1: values[0], Coordinate2: values[1]})
@@ -140,8 +224,14 @@ func parseSingle(content []byte) (airspace.Airspace, bool, error) {
 		case "DP":
 			aspace.Segments = append(aspace.Segments,
 				airspace.Segment{Type: airspace.Polygon, X: x, Clockwise: clockwise, Coordinate1: value})
+		case "DY":
+			aspace.Segments = append(aspace.Segments,
+				airspace.Segment{Type: airspace.Airway, X: x, Clockwise: clockwise, W: width, Coordinate1: value})
 		case "V":
 			splitequals := strings.Split(value, "=")
+			if len(splitequals) != 2 {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid variable in line %v '%v'", n, line)
+			}
 			varkey := splitequals[0]
 			varvalue := strings.Trim(splitequals[1], " ")
 			switch varkey {
@@ -149,26 +239,44 @@ func parseSingle(content []byte) (airspace.Airspace, bool, error) {
 				x = varvalue
 			case "D":
 				clockwise = (varvalue == "+")
+			case "W":
+				w, err := strconv.ParseFloat(varvalue, 64)
+				if err != nil {
+					return airspace.Airspace{}, false, fmt.Errorf("Invalid airway width in line %v '%v' :: %v", n, line, err)
+				}
+				width = w
 			}
 		case "SP": // pen to draw (including color)
 			split := strings.Split(value, ",")
+			if len(split) != 5 {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid pen in line %v '%v'", n, line)
+			}
+			style, _ := strconv.Atoi(strings.Trim(split[0], " "))
 			width, _ := strconv.Atoi(split[1])
 			r, _ := strconv.Atoi(split[2])
 			g, _ := strconv.Atoi(split[3])
 			b, _ := strconv.Atoi(split[4])
-			airspacePens[aspace.Class] = airspace.Pen{
-				Style: airspace.Solid, Width: width,
-				Color:       color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: 1.0},
-				InsideColor: color.RGBA64{},
+			// the brush is kept, transparent if not set yet
+			pen := p.pens[aspace.Class]
+			pen.Style, pen.Width = styleToAirspace(style), width
+			pen.Color = color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: 1.0}
+			if pen.InsideColor == nil {
+				pen.InsideColor = color.RGBA64{}
+			}
+			p.pens[aspace.Class], aspace.Pen = pen, pen
+		case "SB": // brush to draw (color)
+			split := strings.Split(value, ",")
+			if len(split) != 3 {
+				return airspace.Airspace{}, false, fmt.Errorf("Invalid brush in line %v '%v'", n, line)
 			}
-		case "SB": // brush to draw (color) TODO:
-			//split := strings.Split(value, ",")
-			//r, _ := strconv.Atoi(split[0])
-			//g, _ := strconv.Atoi(split[1])
-			//b, _ := strconv.Atoi(split[2])
-			//airspacePens[airspace.Class].InsideColor = color.RGBA64{}
+			r, _ := strconv.Atoi(strings.Trim(split[0], " "))
+			g, _ := strconv.Atoi(strings.Trim(split[1], " "))
+			b, _ := strconv.Atoi(strings.Trim(split[2], " "))
+			pen := p.pens[aspace.Class]
+			pen.InsideColor = colorToAirspace(r, g, b)
+			p.pens[aspace.Class], aspace.Pen = pen, pen
 		default:
-			return airspace.Airspace{}, false, fmt.Errorf("Unrecognized key '%v' in '%v'", key, line)
+			aspace.Extra = append(aspace.Extra, line)
 		}
 	}
 
@@ -26,6 +26,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/rochaporto/ezgliding/airspace"
@@ -129,7 +131,7 @@ DP 46:10:24 N 005:39:42 E`,
 				Floor: "3500FT AMSL", Ceiling: "FL 195",
 				Segments: []airspace.Segment{
 					airspace.Segment{
-						Type: airspace.Polygon, Clockwise: false,
+						Type: airspace.Polygon, Clockwise: true,
 						Coordinate1: "46:22:03 N 006:33:04 E",
 					},
 				},
@@ -168,7 +170,7 @@ DP 46:22:03 N 006:33:04 E
 				Floor: "3500FT AMSL", Ceiling: "FL 195",
 				Segments: []airspace.Segment{
 					airspace.Segment{
-						Type: airspace.Polygon, Clockwise: false,
+						Type: airspace.Polygon, Clockwise: true,
 						Coordinate1: "46:22:03 N 006:33:04 E",
 					},
 				},
@@ -180,6 +182,52 @@ DP 46:22:03 N 006:33:04 E
 			},
 		},
 	},
+	{"extended airspace",
+		`
+AC G
+SP 1,1,0,128,0
+SB 200,255,200
+*
+AC G
+AY GLIDING
+AN VOLTIGE BAUME
+AH 3000FT AGL
+AL SFC
+AF 122.475
+AG Baume Info
+AA 2015-06-01T08:00Z/2015-06-01T18:00Z
+AT 46:40:00 N 006:30:00 E
+DP 46:40:00 N 006:25:00 E
+V W=5
+DY 46:40:00 N 006:30:00 E
+DY 46:45:00 N 006:35:00 E
+`,
+		[]airspace.Airspace{
+			airspace.Airspace{
+				Class: 'G', Type: "GLIDING", Name: "VOLTIGE BAUME",
+				Floor: "SFC", Ceiling: "3000FT AGL",
+				Frequency: 122.475, Station: "Baume Info",
+				Activation: []string{"2015-06-01T08:00Z/2015-06-01T18:00Z"},
+				Label:      []string{"46:40:00 N 006:30:00 E"},
+				Segments: []airspace.Segment{
+					airspace.Segment{
+						Type: airspace.Polygon, Clockwise: true, Coordinate1: "46:40:00 N 006:25:00 E",
+					},
+					airspace.Segment{
+						Type: airspace.Airway, Clockwise: true, W: 5, Coordinate1: "46:40:00 N 006:30:00 E",
+					},
+					airspace.Segment{
+						Type: airspace.Airway, Clockwise: true, W: 5, Coordinate1: "46:45:00 N 006:35:00 E",
+					},
+				},
+				Pen: airspace.Pen{
+					Style: airspace.Dash, Width: 1,
+					Color:       color.RGBA64{R: 0, G: 128, B: 0, A: 1.0},
+					InsideColor: color.RGBA64{R: 200, G: 255, B: 200, A: 1.0},
+				},
+			},
+		},
+	},
 }
 
 func TestParse(t *testing.T) {
@@ -200,10 +248,134 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseCRLF(t *testing.T) {
+	content := strings.Replace(parseTests[2].c, "\n", "\r\n", -1)
+	airspace, err := Parse([]byte(content))
+	if err != nil {
+		t.Errorf("Failed to parse :: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(airspace, parseTests[2].r) {
+		t.Errorf("Failed to parse CRLF content\nr: %v\ne: %v", airspace, parseTests[2].r)
+	}
+}
+
+func TestParseNoSeparator(t *testing.T) {
+	content := "** header **\nAC C\nAN TMA 1 **\nDP 46:22:03 N 006:33:04 E\nAC D\nAN CTR 2\nDP 46:10:24 N 005:39:42 E"
+	airspace, err := Parse([]byte(content))
+	if err != nil || len(airspace) != 2 {
+		t.Errorf("Expected 2 airspaces but got %v :: %v", len(airspace), err)
+		return
+	}
+	if airspace[0].Name != "TMA 1 **" || airspace[1].Class != 'D' || len(airspace[1].Segments) != 1 {
+		t.Errorf("Failed to parse airspaces :: %+v", airspace)
+	}
+}
+
+func TestParserPens(t *testing.T) {
+	pens := "AC C\nSP 0,2,0,0,255\nSB -1,-1,-1\n"
+	single := "AC C\nAN TMA\nDP 46:22:03 N 006:33:04 E\n"
+	p := NewParser()
+	airspace, err := p.Parse(strings.NewReader(pens + single))
+	if err != nil || len(airspace) != 1 || airspace[0].Pen.Width != 2 {
+		t.Errorf("Expected pen from same content but got %+v :: %v", airspace, err)
+	}
+	airspace, err = p.Parse(strings.NewReader(single))
+	if err != nil || len(airspace) != 1 || airspace[0].Pen.Color != nil {
+		t.Errorf("Expected no pen from previous parse but got %+v :: %v", airspace, err)
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	content, err := ioutil.ReadFile("./test-airspace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse :: %v", err)
+	}
+	var wg sync.WaitGroup
+	results := make([][]airspace.Airspace, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = Parse(content)
+		}(i)
+	}
+	wg.Wait()
+	for i, r := range results {
+		if !reflect.DeepEqual(r, expected) {
+			t.Errorf("Concurrent parse %v differs from sequential parse", i)
+		}
+	}
+}
+
 func TestParseUnknownSegment(t *testing.T) {
-	_, err := Parse([]byte("AC A\nNN 1.0"))
-	if err == nil {
-		t.Errorf("Parsing unknown segment should fail")
+	airspace, err := Parse([]byte("AC A\nAN TMA\nNN 1.0\nXY"))
+	if err != nil {
+		t.Errorf("Failed to parse unknown segment :: %v", err)
+		return
+	}
+	extra := []string{"NN 1.0", "XY"}
+	if len(airspace) != 1 || !reflect.DeepEqual(airspace[0].Extra, extra) {
+		t.Errorf("Expected extra lines %v but got %+v", extra, airspace)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"AC A\nAN TMA\nAF 123,45",
+		"AC A\nAN TMA\nV W=wide",
+		"AC A\nAN TMA\nSB 255,0",
+		"AC\nAN TMA",
+		"AC A\nAN TMA\nV",
+		"AC A\nAN TMA\nV X",
+		"AC A\nAN TMA\nDA 2.5,10",
+		"AC A\nAN TMA\nDB 46:22:03 N 006:33:04 E",
+		"AC A\nAN TMA\nSP 0,2",
+	}
+	for _, test := range tests {
+		if _, err := Parse([]byte(test)); err == nil {
+			t.Errorf("Parsing '%v' should fail", test)
+		}
+	}
+}
+
+func TestParseInvalidLine(t *testing.T) {
+	_, err := Parse([]byte("AC A\nAN TMA\n*\nAC B\nAN TMB\n\nV D\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 7") {
+		t.Errorf("Expected error in line 7 but got %v", err)
+	}
+}
+
+func TestParseBrush(t *testing.T) {
+	tests := []struct {
+		t       string
+		content string
+		pen     airspace.Pen
+	}{
+		{"brush in airspace", "AC R\nSB 200,255,200\nAN R 1\nDP 46:22:03 N 006:33:04 E\n",
+			airspace.Pen{InsideColor: color.RGBA64{R: 200, G: 255, B: 200, A: 1}}},
+		{"pen and brush in airspace", "AC R\nSP 0,1,0,128,0\nSB 200,255,200\nAN R 1\n",
+			airspace.Pen{Style: airspace.Solid, Width: 1, Color: color.RGBA64{G: 128, A: 1},
+				InsideColor: color.RGBA64{R: 200, G: 255, B: 200, A: 1}}},
+		{"brush kept by later pen", "AC R\nSB 200,255,200\nSP 0,1,0,128,0\nAN R 1\n",
+			airspace.Pen{Style: airspace.Solid, Width: 1, Color: color.RGBA64{G: 128, A: 1},
+				InsideColor: color.RGBA64{R: 200, G: 255, B: 200, A: 1}}},
+		{"brush in previous block", "AC R\nSB 200,255,200\n*\nAC R\nAN R 1\n",
+			airspace.Pen{InsideColor: color.RGBA64{R: 200, G: 255, B: 200, A: 1}}},
+	}
+	for _, test := range tests {
+		result, err := Parse([]byte(test.content))
+		if err != nil || len(result) != 1 {
+			t.Errorf("%v :: expected one airspace got %+v :: %v", test.t, result, err)
+			continue
+		}
+		if !reflect.DeepEqual(result[0].Pen, test.pen) {
+			t.Errorf("%v :: expected pen %+v got %+v", test.t, test.pen, result[0].Pen)
+		}
 	}
 }
 
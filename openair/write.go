@@ -0,0 +1,176 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openair
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/rochaporto/ezgliding/airspace"
+)
+
+// Write writes the given airspaces to w in the OpenAir format.
+//
+// Pens are written in the block of the first airspace using them in each
+// class, right after AC, as pens set with SP apply to the following
+// airspaces of the same class. An airspace with no pen following one with a
+// pen in the same class can not be expressed, and will get that pen when
+// parsed back.
+// Segment directions start at the clockwise default in each airspace, so
+// V D=- is always written before the first counter clockwise segment.
+func Write(w io.Writer, airspaces []airspace.Airspace) error {
+	pens := map[byte]airspace.Pen{}
+	for _, a := range airspaces {
+		var buf bytes.Buffer
+		pen := a.Pen.Color != nil && !reflect.DeepEqual(a.Pen, pens[a.Class])
+		if pen {
+			pens[a.Class] = a.Pen
+		}
+		writeSingle(&buf, a, pen)
+		buf.WriteString("*\n")
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSingle writes the definition of a single airspace, without the
+// block separator, with its pen if requested. It is usually called by Write.
+//
+// Airspace.Extra lines are written as given, after the segments.
+func writeSingle(buf *bytes.Buffer, a airspace.Airspace, pen bool) {
+	if a.Class != 0 {
+		fmt.Fprintf(buf, "AC %c\n", a.Class)
+	}
+	if pen {
+		writePen(buf, a.Pen)
+	}
+	if a.Type != "" {
+		fmt.Fprintf(buf, "AY %v\n", a.Type)
+	}
+	fmt.Fprintf(buf, "AN %v\n", a.Name)
+	if a.Ceiling != "" {
+		fmt.Fprintf(buf, "AH %v\n", a.Ceiling)
+	}
+	if a.Floor != "" {
+		fmt.Fprintf(buf, "AL %v\n", a.Floor)
+	}
+	if a.Frequency != 0 {
+		fmt.Fprintf(buf, "AF %.3f\n", a.Frequency)
+	}
+	if a.Station != "" {
+		fmt.Fprintf(buf, "AG %v\n", a.Station)
+	}
+	for _, v := range a.Activation {
+		fmt.Fprintf(buf, "AA %v\n", v)
+	}
+	for _, v := range a.Label {
+		fmt.Fprintf(buf, "AT %v\n", v)
+	}
+	// variables start unset in each airspace, with clockwise arcs (V D=+)
+	var x string
+	clockwise := true
+	var width float64
+	for _, s := range a.Segments {
+		if s.Clockwise != clockwise {
+			clockwise = s.Clockwise
+			if clockwise {
+				buf.WriteString("V D=+\n")
+			} else {
+				buf.WriteString("V D=-\n")
+			}
+		}
+		if s.X != x && s.X != "" {
+			x = s.X
+			fmt.Fprintf(buf, "V X=%v\n", x)
+		}
+		if s.Type == airspace.Airway && s.W != width {
+			width = s.W
+			fmt.Fprintf(buf, "V W=%v\n", formatFloat(width))
+		}
+		switch s.Type {
+		case airspace.Polygon:
+			fmt.Fprintf(buf, "DP %v\n", s.Coordinate1)
+		case airspace.Arc:
+			if s.Coordinate1 != "" {
+				fmt.Fprintf(buf, "DB %v,%v\n", s.Coordinate1, s.Coordinate2)
+			} else {
+				fmt.Fprintf(buf, "DA %v,%v,%v\n", formatFloat(s.Radius),
+					formatFloat(s.AngleStart), formatFloat(s.AngleEnd))
+			}
+		case airspace.Circle:
+			fmt.Fprintf(buf, "DC %v\n", formatFloat(s.Radius))
+		case airspace.Airway:
+			fmt.Fprintf(buf, "DY %v\n", s.Coordinate1)
+		}
+	}
+	for _, l := range a.Extra {
+		buf.WriteString(l + "\n")
+	}
+}
+
+// writePen writes the SP and SB lines for the given pen.
+func writePen(buf *bytes.Buffer, pen airspace.Pen) {
+	r, g, b, _ := colorToOpenAir(pen.Color)
+	fmt.Fprintf(buf, "SP %v,%v,%v,%v,%v\n", styleToOpenAir(pen.Style), pen.Width, r, g, b)
+	r, g, b, a := colorToOpenAir(pen.InsideColor)
+	if a == 0 {
+		// transparent
+		r, g, b = -1, -1, -1
+	}
+	fmt.Fprintf(buf, "SB %v,%v,%v\n", r, g, b)
+}
+
+// styleToOpenAir converts the given pen style to the OpenAir value, the
+// reverse of styleToAirspace.
+func styleToOpenAir(style airspace.PenStyle) int {
+	switch style {
+	case airspace.Solid:
+		return 0
+	case airspace.Dash:
+		return 1
+	default:
+		return 5
+	}
+}
+
+// colorToOpenAir returns the 8 bit components of the given color, nil being
+// transparent. Colors parsed from OpenAir keep the 8 bit components in a
+// color.RGBA64, and are returned as is.
+func colorToOpenAir(c color.Color) (int, int, int, int) {
+	switch v := c.(type) {
+	case nil:
+		return 0, 0, 0, 0
+	case color.RGBA64:
+		return int(v.R), int(v.G), int(v.B), int(v.A)
+	}
+	v := color.RGBAModel.Convert(c).(color.RGBA)
+	return int(v.R), int(v.G), int(v.B), int(v.A)
+}
+
+// formatFloat formats v with the minimum digits needed to parse it back.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
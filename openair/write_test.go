@@ -0,0 +1,192 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package openair
+
+import (
+	"bytes"
+	"image/color"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/rochaporto/ezgliding/airspace"
+)
+
+// roundTrip writes and parses back the given airspaces.
+func roundTrip(airspaces []airspace.Airspace) ([]airspace.Airspace, string, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, airspaces); err != nil {
+		return nil, "", err
+	}
+	content := buf.String()
+	result, err := Parse(buf.Bytes())
+	return result, content, err
+}
+
+func TestWrite(t *testing.T) {
+	for _, test := range parseTests {
+		expected, err := Parse([]byte(test.c))
+		if err != nil {
+			t.Errorf("%v :: failed to parse :: %v", test.t, err)
+			continue
+		}
+		result, content, err := roundTrip(expected)
+		if err != nil {
+			t.Errorf("%v :: failed to round trip :: %v", test.t, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("%v :: round trip mismatch\n%v\nr: %+v\ne: %+v", test.t, content, result, expected)
+		}
+	}
+}
+
+func TestWriteContent(t *testing.T) {
+	a := airspace.Airspace{
+		Class: 'R', Name: "R 63 MORONVILLIERS", Ceiling: "FL 115", Floor: "SFC",
+		Segments: []airspace.Segment{
+			{Type: airspace.Polygon, Coordinate1: "49:12:00 N 004:12:00 E", Clockwise: true},
+			{Type: airspace.Arc, X: "49:15:00 N 004:20:00 E", Clockwise: true, Radius: 2.5,
+				AngleStart: 10, AngleEnd: 90.5},
+			{Type: airspace.Arc, X: "49:15:00 N 004:20:00 E",
+				Coordinate1: "49:17:00 N 004:20:00 E", Coordinate2: "49:15:00 N 004:24:00 E"},
+			{Type: airspace.Circle, X: "49:10:00 N 004:10:00 E", Radius: 1},
+		},
+		Pen: airspace.Pen{Style: airspace.Dash, Width: 1, Color: color.RGBA{R: 255, A: 255}},
+	}
+	expected := `AC R
+SP 1,1,255,0,0
+SB -1,-1,-1
+AN R 63 MORONVILLIERS
+AH FL 115
+AL SFC
+DP 49:12:00 N 004:12:00 E
+V X=49:15:00 N 004:20:00 E
+DA 2.5,10,90.5
+V D=-
+DB 49:17:00 N 004:20:00 E,49:15:00 N 004:24:00 E
+V X=49:10:00 N 004:10:00 E
+DC 1
+*
+AC R
+AN R 64
+*
+`
+	var buf bytes.Buffer
+	if err := Write(&buf, []airspace.Airspace{a, {Class: 'R', Name: "R 64", Pen: a.Pen}}); err != nil {
+		t.Fatalf("failed to write :: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, buf.String())
+	}
+}
+
+func TestWriteDirection(t *testing.T) {
+	ccw := airspace.Segment{Type: airspace.Arc, X: "49:15:00 N 004:20:00 E",
+		Radius: 2.5, AngleStart: 10, AngleEnd: 90}
+	cw := ccw
+	cw.Clockwise = true
+	tests := []struct {
+		t        string
+		segments [][]airspace.Segment
+		expected string
+	}{
+		{"clockwise arc", [][]airspace.Segment{{cw}},
+			"AC R\nAN R 1\nV X=49:15:00 N 004:20:00 E\nDA 2.5,10,90\n*\n"},
+		{"counter clockwise arc", [][]airspace.Segment{{ccw}},
+			"AC R\nAN R 1\nV D=-\nV X=49:15:00 N 004:20:00 E\nDA 2.5,10,90\n*\n"},
+		{"mixed arcs", [][]airspace.Segment{{ccw, cw, ccw}},
+			"AC R\nAN R 1\nV D=-\nV X=49:15:00 N 004:20:00 E\nDA 2.5,10,90\nV D=+\nDA 2.5,10,90\n" +
+				"V D=-\nDA 2.5,10,90\n*\n"},
+		{"counter clockwise in each airspace", [][]airspace.Segment{{ccw}, {ccw}},
+			"AC R\nAN R 1\nV D=-\nV X=49:15:00 N 004:20:00 E\nDA 2.5,10,90\n*\n" +
+				"AC R\nAN R 1\nV D=-\nV X=49:15:00 N 004:20:00 E\nDA 2.5,10,90\n*\n"},
+	}
+	for _, test := range tests {
+		var airspaces []airspace.Airspace
+		for _, s := range test.segments {
+			airspaces = append(airspaces, airspace.Airspace{Class: 'R', Name: "R 1", Segments: s})
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, airspaces); err != nil {
+			t.Errorf("%v :: failed to write :: %v", test.t, err)
+			continue
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%v :: expected\n%v\ngot\n%v", test.t, test.expected, buf.String())
+		}
+	}
+}
+
+func TestWritePenStyles(t *testing.T) {
+	for _, style := range []airspace.PenStyle{airspace.Solid, airspace.Dash, airspace.None} {
+		a := airspace.Airspace{Class: 'R', Name: "R 1", Pen: airspace.Pen{Style: style, Width: 2,
+			Color: color.RGBA64{R: 255, A: 1}, InsideColor: color.RGBA64{}}}
+		result, content, err := roundTrip([]airspace.Airspace{a})
+		if err != nil || len(result) != 1 || !reflect.DeepEqual(result[0].Pen, a.Pen) {
+			t.Errorf("%v :: expected pen %+v got %+v :: %v\n%v", style, a.Pen, result, err, content)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	content, err := ioutil.ReadFile("./test-airspace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Parse(content)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	if len(expected) < 100 {
+		t.Fatalf("expected more than 100 airspaces got %v", len(expected))
+	}
+	result, _, err := roundTrip(expected)
+	if err != nil {
+		t.Fatalf("failed to round trip :: %v", err)
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("expected %v airspaces got %v", len(expected), len(result))
+	}
+	for i := range result {
+		if !reflect.DeepEqual(result[i], expected[i]) {
+			t.Errorf("round trip mismatch\nr: %+v\ne: %+v", result[i], expected[i])
+		}
+	}
+}
+
+func TestColorToOpenAir(t *testing.T) {
+	tests := []struct {
+		c          color.Color
+		r, g, b, a int
+	}{
+		{nil, 0, 0, 0, 0},
+		{color.RGBA64{R: 200, G: 200, B: 255, A: 1}, 200, 200, 255, 1},
+		{color.RGBA{R: 10, G: 20, B: 30, A: 255}, 10, 20, 30, 255},
+		{color.NRGBA{R: 10, G: 20, B: 30, A: 255}, 10, 20, 30, 255},
+	}
+	for _, test := range tests {
+		r, g, b, a := colorToOpenAir(test.c)
+		if r != test.r || g != test.g || b != test.b || a != test.a {
+			t.Errorf("expected %v %v %v %v for %v got %v %v %v %v", test.r, test.g, test.b, test.a,
+				test.c, r, g, b, a)
+		}
+	}
+}
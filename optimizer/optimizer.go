@@ -0,0 +1,401 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package optimizer finds the best cross country courses in flight tracks:
+// free distance, out-and-return, flat and FAI triangles.
+//
+// The search is done over a downsampled track, with the result refined
+// using all the points around each of the chosen ones.
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// Type is the type of a course.
+type Type int
+
+const (
+	// FreeDistance is an open course through up to Options.Turnpoints.
+	FreeDistance Type = iota
+	// OutAndReturn is a closed course with a single turnpoint.
+	OutAndReturn
+	// Triangle is a closed course with three turnpoints.
+	Triangle
+	// FAITriangle is a triangle where each leg is at least Options.FAILeg
+	// of the total distance.
+	FAITriangle
+)
+
+func (t Type) String() string {
+	switch t {
+	case FreeDistance:
+		return "free distance"
+	case OutAndReturn:
+		return "out and return"
+	case Triangle:
+		return "triangle"
+	case FAITriangle:
+		return "fai triangle"
+	default:
+		return "unknown"
+	}
+}
+
+// Options holds the optimizer configuration.
+type Options struct {
+	// Turnpoints is the max number of turnpoints for free distance, up to
+	// MaxTurnpoints.
+	Turnpoints int
+	// MaxPoints is the max number of track points used in the search.
+	MaxPoints int
+	// ClosingRatio is the max closing distance (between start and finish)
+	// of closed courses, as a ratio of the course distance.
+	ClosingRatio float64
+	// MaxClosing is the max closing distance (km), 0 for no limit.
+	MaxClosing float64
+	// FAILeg is the min leg size of FAI triangles, as a ratio of the
+	// course distance.
+	FAILeg float64
+}
+
+// MaxTurnpoints is the max value of Options.Turnpoints.
+const MaxTurnpoints = 5
+
+// DefaultOptions are the options used in Optimize.
+var DefaultOptions = Options{
+	Turnpoints:   3,
+	MaxPoints:    600,
+	ClosingRatio: 0.2,
+	FAILeg:       0.28,
+}
+
+// Result is an optimized course.
+type Result struct {
+	Type Type
+	// Points holds the start, turnpoints and finish of the course, and
+	// Indices their indices in the flight points.
+	Points  []flight.Point
+	Indices []int
+	// Distance is the course distance (km). For closed courses this is the
+	// distance between the turnpoints (going back to the first one).
+	Distance float64
+	// Closing is the distance (km) between start and finish of closed
+	// courses.
+	Closing float64
+	// Score is the distance (km) counted for the course, the distance minus
+	// the closing distance for closed courses.
+	Score float64
+}
+
+// Optimize returns the best course of each type for the given flight, using
+// DefaultOptions. Types for which no valid course is found are omitted.
+func Optimize(f flight.Flight) ([]Result, error) {
+	return OptimizeWith(f, DefaultOptions)
+}
+
+// OptimizeWith returns the best course of each type for the given flight,
+// using the given options.
+func OptimizeWith(f flight.Flight, opts Options) ([]Result, error) {
+	if len(f.Points) < 2 {
+		return nil, errors.New("not enough points to optimize")
+	}
+	if opts.Turnpoints < 0 || opts.Turnpoints > MaxTurnpoints {
+		return nil, fmt.Errorf("turnpoints must be between 0 and %v", MaxTurnpoints)
+	}
+	o := newOptimizer(f.Points, opts)
+	var results []Result
+	for _, t := range []Type{FreeDistance, OutAndReturn, Triangle, FAITriangle} {
+		if r, ok := o.best(t); ok {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// optimizer holds the track and the downsampled points used in the search.
+type optimizer struct {
+	points []flight.Point
+	opts   Options
+	// sample holds the indices in points of the downsampled track, step
+	// being the number of points between two samples.
+	sample []int
+	step   int
+	// dist is the distance matrix (km) between sample points.
+	dist []float64
+	// closing[i*n+j] is the min distance between a sample before or at i
+	// and one after or at j.
+	closing []float64
+}
+
+func newOptimizer(points []flight.Point, opts Options) *optimizer {
+	o := optimizer{points: points, opts: opts, step: 1}
+	if opts.MaxPoints > 1 && len(points) > opts.MaxPoints {
+		o.step = (len(points) + opts.MaxPoints - 1) / opts.MaxPoints
+	}
+	for i := 0; i < len(points); i += o.step {
+		o.sample = append(o.sample, i)
+	}
+	if last := len(points) - 1; o.sample[len(o.sample)-1] != last {
+		o.sample = append(o.sample, last)
+	}
+	n := len(o.sample)
+	o.dist = make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := o.distance(o.sample[i], o.sample[j])
+			o.dist[i*n+j], o.dist[j*n+i] = d, d
+		}
+	}
+	o.closing = make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := n - 1; j >= i; j-- {
+			c := o.dist[i*n+j]
+			if i > 0 {
+				c = math.Min(c, o.closing[(i-1)*n+j])
+			}
+			if j < n-1 {
+				c = math.Min(c, o.closing[i*n+j+1])
+			}
+			o.closing[i*n+j] = c
+		}
+	}
+	return &o
+}
+
+// distance returns the distance (km) between points i and j.
+func (o *optimizer) distance(i, j int) float64 {
+	pi, pj := o.points[i], o.points[j]
+	return spatial.Distance(pi.Latitude, pi.Longitude, pj.Latitude, pj.Longitude) / 1000
+}
+
+// best returns the best course of the given type.
+func (o *optimizer) best(t Type) (Result, bool) {
+	var idx []int
+	switch t {
+	case FreeDistance:
+		idx = o.freeDistance()
+	case OutAndReturn:
+		idx = o.outAndReturn()
+	default:
+		idx = o.triangle(t == FAITriangle)
+	}
+	if idx == nil {
+		return Result{}, false
+	}
+	for k := range idx {
+		idx[k] = o.sample[idx[k]]
+	}
+	idx = o.refine(t, idx)
+	r, ok := o.evaluate(t, idx)
+	return r, ok
+}
+
+// freeDistance returns the sample indices of the best free distance, using
+// dynamic programming over the number of legs.
+func (o *optimizer) freeDistance() []int {
+	n, legs := len(o.sample), o.opts.Turnpoints+1
+	best := make([]float64, n)
+	prev := make([][]int, legs)
+	for l := 0; l < legs; l++ {
+		next := make([]float64, n)
+		prev[l] = make([]int, n)
+		for j := 0; j < n; j++ {
+			for i := 0; i <= j; i++ {
+				if d := best[i] + o.dist[i*n+j]; d > next[j] || i == 0 {
+					next[j], prev[l][j] = d, i
+				}
+			}
+		}
+		best = next
+	}
+	end := 0
+	for j := range best {
+		if best[j] > best[end] {
+			end = j
+		}
+	}
+	idx := make([]int, legs+1)
+	idx[legs] = end
+	for l := legs - 1; l >= 0; l-- {
+		idx[l] = prev[l][idx[l+1]]
+	}
+	return idx
+}
+
+// outAndReturn returns the sample indices (start, turnpoint and finish) of
+// the best out-and-return.
+func (o *optimizer) outAndReturn() []int {
+	n := len(o.sample)
+	var idx []int
+	score := 0.0
+	for s := 0; s < n; s++ {
+		for f := n - 1; f > s; f-- {
+			closing := o.dist[s*n+f]
+			for t := s + 1; t < f; t++ {
+				d := o.dist[s*n+t] + o.dist[t*n+f]
+				if d-closing > score && o.closes(d, closing) {
+					idx, score = []int{s, t, f}, d-closing
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// triangle returns the sample indices (start, three turnpoints and finish)
+// of the best triangle, FAI if requested.
+func (o *optimizer) triangle(fai bool) []int {
+	n := len(o.sample)
+	var tps []int
+	score := 0.0
+	for t1 := 0; t1 < n; t1++ {
+		for t3 := n - 1; t3 > t1+1; t3-- {
+			d13 := o.dist[t1*n+t3]
+			closing := o.closing[t1*n+t3]
+			for t2 := t1 + 1; t2 < t3; t2++ {
+				d12, d23 := o.dist[t1*n+t2], o.dist[t2*n+t3]
+				d := d12 + d23 + d13
+				if d-closing <= score || !o.closes(d, closing) {
+					continue
+				}
+				if fai && !o.isFAI(d12, d23, d13) {
+					continue
+				}
+				tps, score = []int{t1, t2, t3}, d-closing
+			}
+		}
+	}
+	if tps == nil {
+		return nil
+	}
+	// find the start and finish giving the closing distance
+	t1, t3 := tps[0], tps[2]
+	closing := o.closing[t1*n+t3]
+	for s := t1; s >= 0; s-- {
+		for f := t3; f < n; f++ {
+			if o.dist[s*n+f] == closing {
+				return []int{s, t1, tps[1], t3, f}
+			}
+		}
+	}
+	return nil
+}
+
+// closes checks the closing distance for a course of distance d.
+func (o *optimizer) closes(d, closing float64) bool {
+	if o.opts.MaxClosing > 0 && closing > o.opts.MaxClosing {
+		return false
+	}
+	return closing <= o.opts.ClosingRatio*d
+}
+
+// isFAI checks the legs against the min FAI leg size.
+func (o *optimizer) isFAI(legs ...float64) bool {
+	d := 0.0
+	for _, l := range legs {
+		d += l
+	}
+	for _, l := range legs {
+		if l < o.opts.FAILeg*d {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate returns the result for the course given by the point indices,
+// and false if it is not valid for the type.
+func (o *optimizer) evaluate(t Type, idx []int) (Result, bool) {
+	r := Result{Type: t, Indices: idx}
+	for k := 1; k < len(idx); k++ {
+		if idx[k] < idx[k-1] {
+			return r, false
+		}
+	}
+	switch t {
+	case FreeDistance:
+		for k := 1; k < len(idx); k++ {
+			r.Distance += o.distance(idx[k-1], idx[k])
+		}
+		r.Score = r.Distance
+	case OutAndReturn:
+		r.Distance = o.distance(idx[0], idx[1]) + o.distance(idx[1], idx[2])
+		r.Closing = o.distance(idx[0], idx[2])
+	default:
+		legs := []float64{o.distance(idx[1], idx[2]), o.distance(idx[2], idx[3]), o.distance(idx[3], idx[1])}
+		r.Distance = legs[0] + legs[1] + legs[2]
+		r.Closing = o.distance(idx[0], idx[4])
+		if t == FAITriangle && !o.isFAI(legs...) {
+			return r, false
+		}
+	}
+	if t != FreeDistance {
+		if !o.closes(r.Distance, r.Closing) {
+			return r, false
+		}
+		r.Score = r.Distance - r.Closing
+	}
+	for _, i := range idx {
+		r.Points = append(r.Points, o.points[i])
+	}
+	return r, true
+}
+
+// refine improves the course found in the downsampled track, moving each
+// point to the best one around it in the full track, until no improvement
+// is found.
+func (o *optimizer) refine(t Type, idx []int) []int {
+	best, ok := o.evaluate(t, idx)
+	if !ok || o.step == 1 {
+		return idx
+	}
+	for improved := true; improved; {
+		improved = false
+		for k := range idx {
+			from, to := idx[k]-o.step, idx[k]+o.step
+			if k > 0 && from < idx[k-1] {
+				from = idx[k-1]
+			}
+			if k < len(idx)-1 && to > idx[k+1] {
+				to = idx[k+1]
+			}
+			if from < 0 {
+				from = 0
+			}
+			if to > len(o.points)-1 {
+				to = len(o.points) - 1
+			}
+			current := idx[k]
+			for i := from; i <= to; i++ {
+				idx[k] = i
+				if r, ok := o.evaluate(t, idx); ok && r.Score > best.Score+1e-9 {
+					best, current, improved = r, i, true
+				}
+			}
+			idx[k] = current
+		}
+	}
+	return idx
+}
@@ -0,0 +1,304 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package optimizer
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// track returns a flight going through the given legs (bearing and distance
+// in km) from a fixed start, with the given spacing (km) between points.
+func track(spacing float64, legs ...[2]float64) flight.Flight {
+	f := flight.NewFlight()
+	lat, lon := 45.0, 5.0
+	t := time.Date(2015, 6, 20, 10, 0, 0, 0, time.UTC)
+	add := func() {
+		p := flight.NewPoint()
+		p.Time, p.Latitude, p.Longitude = t, lat, lon
+		f.Points = append(f.Points, p)
+		t = t.Add(4 * time.Second)
+	}
+	add()
+	for _, leg := range legs {
+		n := int(leg[1]/spacing + 0.5)
+		for i := 0; i < n; i++ {
+			lat, lon = spatial.Destination(lat, lon, leg[0], leg[1]*1000/float64(n))
+			add()
+		}
+	}
+	return f
+}
+
+type optimizeTest struct {
+	t string
+	f flight.Flight
+	// expected score (km) for each type, 0 if none is expected and -1 if
+	// not checked (it is checked against brute force in smaller tracks)
+	scores map[Type]float64
+}
+
+var optimizeTests = []optimizeTest{
+	{
+		t:      "straight line",
+		f:      track(0.1, [2]float64{0, 100}),
+		scores: map[Type]float64{FreeDistance: 100},
+	},
+	{
+		t: "out and return",
+		f: track(0.1, [2]float64{0, 50}, [2]float64{180, 50}),
+		// a flat triangle can use the out and return legs
+		scores: map[Type]float64{FreeDistance: 100, OutAndReturn: 100, Triangle: 100},
+	},
+	{
+		t: "fai triangle",
+		f: track(0.1, [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100}),
+		scores: map[Type]float64{FreeDistance: 300, OutAndReturn: -1,
+			Triangle: 300, FAITriangle: 300},
+	},
+	{
+		t: "flat triangle",
+		f: track(0.1, [2]float64{0, 100}, [2]float64{100, 20}, [2]float64{190, 100}),
+		scores: map[Type]float64{FreeDistance: 220, OutAndReturn: -1,
+			Triangle: -1, FAITriangle: -1},
+	},
+}
+
+func TestOptimize(t *testing.T) {
+	for _, test := range optimizeTests {
+		results, err := Optimize(test.f)
+		if err != nil {
+			t.Errorf("%v :: unexpected error :: %v", test.t, err)
+			continue
+		}
+		found := map[Type]Result{}
+		for _, r := range results {
+			found[r.Type] = r
+		}
+		for _, typ := range []Type{FreeDistance, OutAndReturn, Triangle, FAITriangle} {
+			r, ok := found[typ]
+			expected := test.scores[typ]
+			if !ok {
+				if expected != 0 {
+					t.Errorf("%v :: expected %v with score %v got none", test.t, typ, expected)
+				}
+				continue
+			}
+			if expected == 0 {
+				t.Errorf("%v :: expected no %v got %+v", test.t, typ, r.Indices)
+				continue
+			}
+			// the score depends on the track resolution (10 points per km)
+			if expected > 0 && math.Abs(r.Score-expected) > 0.01*expected+0.2 {
+				t.Errorf("%v :: expected %v with score %v got %v", test.t, typ, expected, r.Score)
+			}
+			checkResult(t, test.t, test.f, r, DefaultOptions)
+		}
+	}
+}
+
+// checkResult checks the points and constraints of the given result.
+func checkResult(t *testing.T, name string, f flight.Flight, r Result, opts Options) {
+	if len(r.Points) != len(r.Indices) {
+		t.Errorf("%v :: %v points do not match indices", name, r.Type)
+	}
+	for k, i := range r.Indices {
+		if k > 0 && i < r.Indices[k-1] {
+			t.Errorf("%v :: %v indices not in order :: %v", name, r.Type, r.Indices)
+		}
+		if f.Points[i].Time != r.Points[k].Time || f.Points[i].Latitude != r.Points[k].Latitude {
+			t.Errorf("%v :: %v point %v does not match index", name, r.Type, k)
+		}
+	}
+	if r.Type != FreeDistance && r.Closing > opts.ClosingRatio*r.Distance {
+		t.Errorf("%v :: %v closing %v too big for %v", name, r.Type, r.Closing, r.Distance)
+	}
+	if r.Type == FAITriangle {
+		p := r.Points
+		legs := []float64{
+			spatial.Distance(p[1].Latitude, p[1].Longitude, p[2].Latitude, p[2].Longitude),
+			spatial.Distance(p[2].Latitude, p[2].Longitude, p[3].Latitude, p[3].Longitude),
+			spatial.Distance(p[3].Latitude, p[3].Longitude, p[1].Latitude, p[1].Longitude),
+		}
+		for _, l := range legs {
+			if l/1000 < opts.FAILeg*r.Distance {
+				t.Errorf("%v :: leg %v too short for fai triangle %v", name, l, r.Distance)
+			}
+		}
+	}
+}
+
+// bruteForce returns the best score of the given type, checking all
+// possible courses.
+func bruteForce(f flight.Flight, typ Type, opts Options) float64 {
+	o := newOptimizer(f.Points, opts)
+	n := len(f.Points)
+	best := 0.0
+	var search func(idx []int, size int)
+	search = func(idx []int, size int) {
+		if len(idx) == size {
+			if r, ok := o.evaluate(typ, append([]int(nil), idx...)); ok && r.Score > best {
+				best = r.Score
+			}
+			return
+		}
+		from := 0
+		if len(idx) > 0 {
+			from = idx[len(idx)-1]
+		}
+		for i := from; i < n; i++ {
+			search(append(idx, i), size)
+		}
+	}
+	sizes := map[Type]int{FreeDistance: opts.Turnpoints + 2, OutAndReturn: 3, Triangle: 5, FAITriangle: 5}
+	search(nil, sizes[typ])
+	return best
+}
+
+func TestOptimizeBruteForce(t *testing.T) {
+	tracks := []flight.Flight{
+		track(10, [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100}),
+		track(10, [2]float64{0, 100}, [2]float64{100, 20}, [2]float64{190, 100}),
+		track(10, [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 80}),
+		track(7, [2]float64{30, 40}, [2]float64{300, 30}, [2]float64{170, 50}, [2]float64{80, 20}),
+	}
+	downsampled := DefaultOptions
+	downsampled.MaxPoints = 12
+	for i, f := range tracks {
+		exact, err := Optimize(f)
+		if err != nil {
+			t.Fatalf("%v :: unexpected error :: %v", i, err)
+		}
+		approx, err := OptimizeWith(f, downsampled)
+		if err != nil {
+			t.Fatalf("%v :: unexpected error :: %v", i, err)
+		}
+		scores := map[Type][2]float64{}
+		for _, r := range exact {
+			s := scores[r.Type]
+			s[0] = r.Score
+			scores[r.Type] = s
+		}
+		for _, r := range approx {
+			s := scores[r.Type]
+			s[1] = r.Score
+			scores[r.Type] = s
+		}
+		for _, typ := range []Type{FreeDistance, OutAndReturn, Triangle, FAITriangle} {
+			expected := bruteForce(f, typ, DefaultOptions)
+			if math.Abs(scores[typ][0]-expected) > 1e-6 {
+				t.Errorf("%v :: expected %v score %v got %v", i, typ, expected, scores[typ][0])
+			}
+			// the downsampled search is refined, but may miss the best
+			if scores[typ][1] > expected+1e-6 || scores[typ][1] < 0.85*expected {
+				t.Errorf("%v :: expected downsampled %v score near %v got %v", i, typ, expected, scores[typ][1])
+			}
+		}
+	}
+}
+
+func TestOptimizeOptions(t *testing.T) {
+	f := track(0.1, [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 80})
+	opts := DefaultOptions
+	opts.MaxClosing = 5
+	results, err := OptimizeWith(f, opts)
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	for _, r := range results {
+		if r.Type != FreeDistance && r.Closing > 5 {
+			t.Errorf("expected closing below 5 km got %v for %v", r.Closing, r.Type)
+		}
+		if r.Type == Triangle && r.Score > 270 {
+			t.Errorf("expected smaller triangle got %v", r.Score)
+		}
+	}
+
+	opts = DefaultOptions
+	opts.Turnpoints = 0
+	results, err = OptimizeWith(track(0.1, [2]float64{0, 50}, [2]float64{90, 50}), opts)
+	if err != nil || len(results) == 0 || results[0].Type != FreeDistance {
+		t.Fatalf("expected free distance got %+v :: %v", results, err)
+	}
+	if len(results[0].Indices) != 2 || math.Abs(results[0].Score-70.7) > 0.5 {
+		t.Errorf("expected straight distance of 70.7 got %v", results[0].Score)
+	}
+}
+
+func TestOptimizeErrors(t *testing.T) {
+	if _, err := Optimize(track(0.1)); err == nil {
+		t.Errorf("expected error with a single point")
+	}
+	opts := DefaultOptions
+	opts.Turnpoints = MaxTurnpoints + 1
+	if _, err := OptimizeWith(track(0.1, [2]float64{0, 10}), opts); err == nil {
+		t.Errorf("expected error with %v turnpoints", opts.Turnpoints)
+	}
+}
+
+func TestOptimizeSample(t *testing.T) {
+	content, err := ioutil.ReadFile("../netcoupe/t/sample-flight.igc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := flight.ParseIGC(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	results, err := Optimize(f)
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("optimization of %v points took too long :: %v", len(f.Points), d)
+	}
+	if len(results) == 0 || results[0].Type != FreeDistance || results[0].Score < 100 {
+		t.Fatalf("expected free distance got %+v", results)
+	}
+	for _, r := range results {
+		checkResult(t, "sample", f, r, DefaultOptions)
+		if r.Score > results[0].Score+0.001 {
+			t.Errorf("%v score %v above free distance %v", r.Type, r.Score, results[0].Score)
+		}
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	types := map[Type]string{FreeDistance: "free distance", OutAndReturn: "out and return",
+		Triangle: "triangle", FAITriangle: "fai triangle", Type(10): "unknown"}
+	for k, v := range types {
+		if k.String() != v {
+			t.Errorf("expected %v got %v", v, k.String())
+		}
+	}
+}
+
+func BenchmarkOptimize(b *testing.B) {
+	f := track(0.03, [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100})
+	for i := 0; i < b.N; i++ {
+		Optimize(f)
+	}
+}
@@ -43,9 +43,11 @@ import (
 	"github.com/rochaporto/ezgliding/airfield"
 	"github.com/rochaporto/ezgliding/airspace"
 	"github.com/rochaporto/ezgliding/config"
+	"github.com/rochaporto/ezgliding/cup"
 	"github.com/rochaporto/ezgliding/flight"
 	"github.com/rochaporto/ezgliding/fusiontables"
 	"github.com/rochaporto/ezgliding/netcoupe"
+	"github.com/rochaporto/ezgliding/openaip"
 	"github.com/rochaporto/ezgliding/soaringweb"
 	"github.com/rochaporto/ezgliding/waypoint"
 	"github.com/rochaporto/ezgliding/welt2000"
@@ -125,12 +127,18 @@ func Register(id string, plugin interface{}) {
 // GetInstance returns a new instance of the requested plugin.
 func GetInstance(id string, cfg config.Config) (interface{}, error) {
 	switch id {
+	case "cup":
+		c, _ := cup.New(cfg.Cup)
+		return c, nil
 	case "fusiontables":
 		ft, _ := fusiontables.New(cfg.FusionTables)
 		return ft, nil
 	case "netcoupe":
 		nc, _ := netcoupe.New(cfg.Netcoupe)
 		return nc, nil
+	case "openaip":
+		op, _ := openaip.New(cfg.OpenAIP)
+		return op, nil
 	case "soaringweb":
 		sw, _ := soaringweb.New(cfg.SoaringWeb)
 		return sw, nil
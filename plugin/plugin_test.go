@@ -24,9 +24,11 @@ import (
 	"testing"
 
 	"github.com/rochaporto/ezgliding/config"
+	"github.com/rochaporto/ezgliding/cup"
 	"github.com/rochaporto/ezgliding/fusiontables"
 	"github.com/rochaporto/ezgliding/mock"
 	"github.com/rochaporto/ezgliding/netcoupe"
+	"github.com/rochaporto/ezgliding/openaip"
 	"github.com/rochaporto/ezgliding/soaringweb"
 	"github.com/rochaporto/ezgliding/welt2000"
 )
@@ -155,6 +157,18 @@ func TestGetWaypointerMissing(t *testing.T) {
 	}
 }
 
+func TestGetInstanceCup(t *testing.T) {
+	e, _ := cup.New(cup.Config{})
+	r, err := GetInstance("cup", config.Config{})
+	if err != nil {
+		t.Errorf("failed to get instance :: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %v but got %v", e, r)
+	}
+}
+
 func TestGetInstanceFusionTables(t *testing.T) {
 	e, _ := fusiontables.New(fusiontables.Config{})
 	r, err := GetInstance("fusiontables", config.Config{})
@@ -179,6 +193,18 @@ func TestGetInstanceNetcoupe(t *testing.T) {
 	}
 }
 
+func TestGetInstanceOpenAIP(t *testing.T) {
+	e, _ := openaip.New(openaip.Config{})
+	r, err := GetInstance("openaip", config.Config{})
+	if err != nil {
+		t.Errorf("failed to get instance :: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %v but got %v", e, r)
+	}
+}
+
 func TestGetInstanceSoaringWeb(t *testing.T) {
 	e, _ := soaringweb.New(soaringweb.Config{})
 	r, err := GetInstance("soaringweb", config.Config{})
@@ -0,0 +1,81 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package scoring
+
+import (
+	"errors"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/optimizer"
+)
+
+// Netcoupe implements the netcoupe rules, where the best course is scored
+// with the multiplier of its circuit type and the glider handicap:
+//
+//	points = distance * multiplier * 100 / handicap
+type Netcoupe struct {
+	// Multipliers holds the multiplier of each circuit type, types
+	// missing are not scored.
+	Multipliers map[optimizer.Type]float64
+	Options     optimizer.Options
+}
+
+// NewNetcoupe returns the netcoupe rules with the default multipliers:
+// 0.8 for free distance ("Libre"), 1.0 for out-and-return and flat
+// triangles and 1.2 for FAI triangles.
+func NewNetcoupe() *Netcoupe {
+	return &Netcoupe{
+		Multipliers: map[optimizer.Type]float64{
+			optimizer.FreeDistance: 0.8,
+			optimizer.OutAndReturn: 1.0,
+			optimizer.Triangle:     1.0,
+			optimizer.FAITriangle:  1.2,
+		},
+		Options: optimizer.DefaultOptions,
+	}
+}
+
+// Score implements Rules.Score().
+func (n *Netcoupe) Score(f flight.Flight) (Score, error) {
+	handicap, err := Handicap(f.Header.GliderType)
+	if err != nil {
+		return Score{}, err
+	}
+	courses, err := optimizer.OptimizeWith(f, n.Options)
+	if err != nil {
+		return Score{}, err
+	}
+	s := Score{Handicap: handicap}
+	for _, c := range courses {
+		// on a tie keep the simpler course (courses are ordered)
+		if p := n.points(c, handicap); p > s.Points+1e-6 {
+			s.Points, s.Courses = p, []optimizer.Result{c}
+		}
+	}
+	if s.Courses == nil {
+		return Score{}, errors.New("no course found")
+	}
+	return s, nil
+}
+
+// points returns the points for the given course.
+func (n *Netcoupe) points(c optimizer.Result, handicap int) float64 {
+	return c.Score * n.Multipliers[c.Type] * 100 / float64(handicap)
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package scoring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/optimizer"
+)
+
+func TestNetcoupePoints(t *testing.T) {
+	// values taken from a netcoupe flight (471.67 km "Libre" in an Arcus)
+	n := NewNetcoupe()
+	p := n.points(optimizer.Result{Type: optimizer.FreeDistance, Score: 471.67}, 115)
+	if math.Abs(p-328.12) > 0.01 {
+		t.Errorf("expected 328.12 points got %v", p)
+	}
+}
+
+type netcoupeTest struct {
+	t      string
+	f      flight.Flight
+	course optimizer.Type
+	points float64
+	err    bool
+}
+
+var netcoupeTests = []netcoupeTest{
+	{
+		t:      "free distance",
+		f:      track("ASW 19", [2]float64{0, 100}, [2]float64{90, 100}),
+		course: optimizer.FreeDistance, points: 0.8 * 200,
+	},
+	{
+		t:      "out and return",
+		f:      track("ASW 19", [2]float64{0, 100}, [2]float64{180, 100}),
+		course: optimizer.OutAndReturn, points: 200,
+	},
+	{
+		t:      "fai triangle",
+		f:      track("ASW 19", [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100}),
+		course: optimizer.FAITriangle, points: 1.2 * 300,
+	},
+	{
+		t:   "unknown glider",
+		f:   track("Unknown", [2]float64{0, 100}),
+		err: true,
+	},
+	{
+		t:   "no points",
+		f:   track("ASW 19"),
+		err: true,
+	},
+}
+
+func TestNetcoupe(t *testing.T) {
+	n := NewNetcoupe()
+	for _, test := range netcoupeTests {
+		s, err := n.Score(test.f)
+		if test.err != (err != nil) {
+			t.Errorf("%v :: expected error %v got %v", test.t, test.err, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if len(s.Courses) != 1 || s.Courses[0].Type != test.course || s.Handicap != 100 ||
+			math.Abs(s.Points-test.points) > 0.01*test.points {
+			t.Errorf("%v :: expected %v with %v points got %+v", test.t, test.course, test.points, s)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package scoring
+
+import (
+	"errors"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/optimizer"
+)
+
+// OLCPlus implements the OLC-plus rules, adding the OLC classic score (free
+// distance through up to five turnpoints) and a factor of the FAI triangle
+// score, both with the glider handicap:
+//
+//	points = (classic + factor * fai triangle) * 100 / handicap
+type OLCPlus struct {
+	// TriangleFactor is the factor applied to the FAI triangle.
+	TriangleFactor float64
+	Options        optimizer.Options
+}
+
+// NewOLCPlus returns the OLC-plus rules, with a triangle factor of 0.3.
+func NewOLCPlus() *OLCPlus {
+	opts := optimizer.DefaultOptions
+	opts.Turnpoints = 5
+	return &OLCPlus{TriangleFactor: 0.3, Options: opts}
+}
+
+// Score implements Rules.Score().
+func (o *OLCPlus) Score(f flight.Flight) (Score, error) {
+	handicap, err := Handicap(f.Header.GliderType)
+	if err != nil {
+		return Score{}, err
+	}
+	courses, err := optimizer.OptimizeWith(f, o.Options)
+	if err != nil {
+		return Score{}, err
+	}
+	s := Score{Handicap: handicap}
+	distance := 0.0
+	for _, c := range courses {
+		switch c.Type {
+		case optimizer.FreeDistance:
+			distance += c.Score
+		case optimizer.FAITriangle:
+			distance += o.TriangleFactor * c.Score
+		default:
+			continue
+		}
+		s.Courses = append(s.Courses, c)
+	}
+	if s.Courses == nil {
+		return Score{}, errors.New("no course found")
+	}
+	s.Points = distance * 100 / float64(handicap)
+	return s, nil
+}
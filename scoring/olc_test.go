@@ -0,0 +1,57 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package scoring
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOLCPlus(t *testing.T) {
+	o := NewOLCPlus()
+
+	// a zig zag uses all five turnpoints
+	f := track("LS 8", [2]float64{0, 20}, [2]float64{90, 20}, [2]float64{0, 20},
+		[2]float64{90, 20}, [2]float64{0, 20}, [2]float64{90, 20})
+	s, err := o.Score(f)
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	if len(s.Courses) != 1 || math.Abs(s.Points-120*100/108.0) > 1 {
+		t.Errorf("expected 111.1 points got %+v", s)
+	}
+
+	// fai triangle adds 0.3 of its distance
+	f = track("LS 8", [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100})
+	s, err = o.Score(f)
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	if len(s.Courses) != 2 || math.Abs(s.Points-1.3*300*100/108) > 0.01*s.Points {
+		t.Errorf("expected 361.1 points got %+v", s)
+	}
+
+	if _, err := o.Score(track("Unknown", [2]float64{0, 10})); err == nil {
+		t.Errorf("expected error with unknown glider")
+	}
+	if _, err := o.Score(track("LS 8")); err == nil {
+		t.Errorf("expected error with no points")
+	}
+}
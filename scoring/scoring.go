@@ -0,0 +1,165 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package scoring computes flight scores following contest rules.
+//
+// Rules are registered by name, with the netcoupe and OLC-plus rules
+// available by default. Contests can register their own.
+package scoring
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/optimizer"
+)
+
+// Rules is implemented by contest scoring rules.
+type Rules interface {
+	// Score returns the score of the given flight.
+	Score(f flight.Flight) (Score, error)
+}
+
+// Score is the result of scoring a flight.
+type Score struct {
+	// Rules is the name of the rules used.
+	Rules string
+	// Courses holds the courses counted in the score.
+	Courses []optimizer.Result
+	// Handicap is the glider handicap index, 100 being the reference.
+	Handicap int
+	Points   float64
+}
+
+// rules holds the registered rules, keyed by name.
+var rules = struct {
+	sync.RWMutex
+	m map[string]Rules
+}{m: map[string]Rules{}}
+
+func init() {
+	Register("netcoupe", NewNetcoupe())
+	Register("olc-plus", NewOLCPlus())
+}
+
+// Register sets the rules with the given name. A nil r removes them.
+func Register(name string, r Rules) error {
+	if name == "" {
+		return errors.New("empty rules name")
+	}
+	rules.Lock()
+	defer rules.Unlock()
+	if r == nil {
+		delete(rules.m, name)
+	} else {
+		rules.m[name] = r
+	}
+	return nil
+}
+
+// Names returns the names of the registered rules, sorted.
+func Names() []string {
+	rules.RLock()
+	defer rules.RUnlock()
+	var names []string
+	for name := range rules.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ScoreFlight returns the score of the flight with the rules of given name.
+func ScoreFlight(f flight.Flight, name string) (Score, error) {
+	rules.RLock()
+	r, ok := rules.m[name]
+	rules.RUnlock()
+	if !ok {
+		return Score{}, fmt.Errorf("unknown rules :: %v", name)
+	}
+	s, err := r.Score(f)
+	if err != nil {
+		return Score{}, err
+	}
+	s.Rules = name
+	return s, nil
+}
+
+// Compare scores the flight with the rules of given name, and returns the
+// score and its difference to the Points in the flight source (as crawled
+// from the online site) of the given plugin ID.
+func Compare(f flight.Flight, name string, source string) (Score, float64, error) {
+	src, ok := f.Sources[source]
+	if !ok {
+		return Score{}, 0, fmt.Errorf("missing source :: %v", source)
+	}
+	s, err := ScoreFlight(f, name)
+	if err != nil {
+		return Score{}, 0, err
+	}
+	return s, s.Points - src.Points, nil
+}
+
+// Handicaps holds the glider handicap indexes (100 being the reference),
+// keyed by glider type. Values follow the DMSt index list.
+var Handicaps = map[string]int{
+	"Antares": 120, "Arcus": 115, "ASG 29": 121, "ASH 25": 122, "ASH 26": 115,
+	"ASH 31": 122, "ASK 13": 79, "ASK 21": 92, "ASK 23": 92, "ASW 15": 97,
+	"ASW 19": 100, "ASW 20": 108, "ASW 22": 124, "ASW 24": 108, "ASW 27": 114,
+	"ASW 28": 108, "Astir": 93, "DG 100": 99, "DG 300": 104, "DG 303": 104,
+	"DG 500": 106, "DG 800": 114, "DG 808": 118, "DG 1000": 111, "Discus": 106,
+	"Discus 2": 108, "Duo Discus": 110, "Grob 103": 91, "Jantar": 102,
+	"Janus": 104, "Ka 6": 82, "Ka 8": 76, "LS 1": 97, "LS 3": 103, "LS 4": 104,
+	"LS 6": 108, "LS 7": 104, "LS 8": 108, "LS 10": 115, "Nimbus 2": 113,
+	"Nimbus 3": 121, "Nimbus 4": 124, "Pegase": 102, "PW 5": 86, "Puchacz": 82,
+	"Twin Astir": 91, "Ventus": 110, "Ventus 2": 116,
+}
+
+// Handicap returns the handicap index of the given glider type. Types are
+// matched ignoring case, spaces and punctuation, to the longest entry in
+// Handicaps they start with (so "LS-8 18m" matches "LS 8").
+func Handicap(gliderType string) (int, error) {
+	t := normalize(gliderType)
+	best, handicap := "", 0
+	for k, v := range Handicaps {
+		if n := normalize(k); strings.HasPrefix(t, n) && len(n) > len(best) {
+			best, handicap = n, v
+		}
+	}
+	if best == "" {
+		return 0, fmt.Errorf("unknown glider type :: %v", gliderType)
+	}
+	return handicap, nil
+}
+
+// normalize returns the given glider type lower case and with letters and
+// digits only.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
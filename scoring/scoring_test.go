@@ -0,0 +1,142 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package scoring
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/optimizer"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// track returns a flight with the given glider type going through the given
+// legs (bearing and distance in km), with a point every 100 m.
+func track(gliderType string, legs ...[2]float64) flight.Flight {
+	f := flight.NewFlight()
+	f.Header.GliderType = gliderType
+	lat, lon := 45.0, 5.0
+	t := time.Date(2015, 6, 20, 10, 0, 0, 0, time.UTC)
+	add := func() {
+		p := flight.NewPoint()
+		p.Time, p.Latitude, p.Longitude = t, lat, lon
+		f.Points = append(f.Points, p)
+		t = t.Add(4 * time.Second)
+	}
+	add()
+	for _, leg := range legs {
+		for i := 0; i < int(leg[1]*10); i++ {
+			lat, lon = spatial.Destination(lat, lon, leg[0], 100)
+			add()
+		}
+	}
+	return f
+}
+
+type handicapTest struct {
+	gliderType string
+	handicap   int
+	err        bool
+}
+
+var handicapTests = []handicapTest{
+	{"LS 4", 104, false}, {"ls4", 104, false}, {"LS-8 18m", 108, false},
+	{"LS10-st", 115, false}, {"LS1-f", 97, false}, {"Arcus (< 750 kg)", 115, false},
+	{"DG 300", 104, false}, {"dg-1000S", 111, false}, {"Discus 2b", 108, false},
+	{"Discus CS", 106, false}, {"Twin Astir", 91, false}, {"Unknown", 0, true},
+	{"", 0, true},
+}
+
+func TestHandicap(t *testing.T) {
+	for _, test := range handicapTests {
+		h, err := Handicap(test.gliderType)
+		if test.err != (err != nil) || h != test.handicap {
+			t.Errorf("%v :: expected %v (err %v) got %v :: %v", test.gliderType, test.handicap,
+				test.err, h, err)
+		}
+	}
+}
+
+type fixedRules struct {
+	points float64
+	err    error
+}
+
+func (r fixedRules) Score(f flight.Flight) (Score, error) {
+	return Score{Points: r.points, Handicap: 100}, r.err
+}
+
+func TestRegister(t *testing.T) {
+	if !reflect.DeepEqual(Names(), []string{"netcoupe", "olc-plus"}) {
+		t.Errorf("unexpected default rules :: %v", Names())
+	}
+	if err := Register("", fixedRules{}); err == nil {
+		t.Errorf("expected error registering empty name")
+	}
+	if err := Register("regional", fixedRules{points: 42}); err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	s, err := ScoreFlight(flight.NewFlight(), "regional")
+	if err != nil || s.Points != 42 || s.Rules != "regional" {
+		t.Errorf("expected regional score got %+v :: %v", s, err)
+	}
+	Register("regional", fixedRules{err: errors.New("failed")})
+	if _, err := ScoreFlight(flight.NewFlight(), "regional"); err == nil {
+		t.Errorf("expected error from rules")
+	}
+	Register("regional", nil)
+	if _, err := ScoreFlight(flight.NewFlight(), "regional"); err == nil {
+		t.Errorf("expected error with removed rules")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	Register("fixed", fixedRules{points: 300})
+	defer Register("fixed", nil)
+	f := flight.NewFlight()
+	f.Sources["netcoupe"] = flight.Source{Points: 310.5}
+	s, diff, err := Compare(f, "fixed", "netcoupe")
+	if err != nil || s.Points != 300 || diff != -10.5 {
+		t.Errorf("expected diff -10.5 got %v :: %v", diff, err)
+	}
+	if _, _, err := Compare(f, "fixed", "other"); err == nil {
+		t.Errorf("expected error with missing source")
+	}
+	if _, _, err := Compare(f, "unknown", "netcoupe"); err == nil {
+		t.Errorf("expected error with unknown rules")
+	}
+}
+
+func TestCompareNetcoupe(t *testing.T) {
+	// 300 km fai triangle, with a 1.2 multiplier and 104 handicap
+	f := track("LS 4", [2]float64{0, 100}, [2]float64{120, 100}, [2]float64{240, 100})
+	f.Sources["netcoupe"] = flight.Source{Points: 346.15}
+	s, diff, err := Compare(f, "netcoupe", "netcoupe")
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	if math.Abs(diff) > 1 || len(s.Courses) != 1 || s.Courses[0].Type != optimizer.FAITriangle {
+		t.Errorf("expected fai triangle with 346.15 points got %+v (diff %v)", s, diff)
+	}
+}
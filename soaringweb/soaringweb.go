@@ -45,7 +45,8 @@ const (
 )
 
 // Regions supported for queries.
-// FIXME: Missing finland and portugal (non standard page references)
+// FIXME: Missing finland and portugal (non standard page references), use
+// the openaip plugin for those
 var Regions = []string{"AF", "AU", "AT", "BE", "HR", "CZ", "DK", "FR",
 	"DE", "HU", "EI", "IT", "LV", "LT", "MK", "NL", "NO", "PL", "SE",
 	"SI", "SK", "ES", "CH", "UK", "CO", "BR"}
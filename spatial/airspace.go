@@ -0,0 +1,230 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rochaporto/ezgliding/airspace"
+)
+
+// NauticalMile is the length of a nautical mile, in meters.
+const NauticalMile = 1852.0
+
+// DefaultArcResolution is the default angle (in degrees) between consecutive
+// points of expanded arcs and circles.
+const DefaultArcResolution = 5.0
+
+// Coordinate is a position in decimal degrees.
+type Coordinate struct {
+	Latitude  float64
+	Longitude float64
+}
+
+var coordinateRegexp = regexp.MustCompile(`^([\d:.]+)\s*([NS])\s*([\d:.]+)\s*([EW])$`)
+
+// ParseCoordinate parses coordinates in the format used in airspace segments,
+// like 44:16:44 N 000:28:29 E or 44:16.73 N 000:28.48 E.
+func ParseCoordinate(s string) (Coordinate, error) {
+	m := coordinateRegexp.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return Coordinate{}, fmt.Errorf("failed to parse coordinate '%v'", s)
+	}
+	lat, err := parseDegrees(m[1])
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("failed to parse coordinate '%v' :: %v", s, err)
+	}
+	lon, err := parseDegrees(m[3])
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("failed to parse coordinate '%v' :: %v", s, err)
+	}
+	if m[2] == "S" {
+		lat = -lat
+	}
+	if m[4] == "W" {
+		lon = -lon
+	}
+	return Coordinate{Latitude: lat, Longitude: lon}, nil
+}
+
+// String returns the coordinate in the format used in airspace segments, with
+// seconds rounded to hundredths.
+func (c Coordinate) String() string {
+	return formatDegrees(c.Latitude, "N", "S", 2) + " " + formatDegrees(c.Longitude, "E", "W", 3)
+}
+
+// formatDegrees formats degrees as D:M:S, using the given hemisphere letters
+// and the given number of digits for degrees.
+func formatDegrees(v float64, positive string, negative string, digits int) string {
+	hemisphere := positive
+	if v < 0 {
+		hemisphere = negative
+	}
+	// hundredths of seconds
+	h := int64(math.Floor(math.Abs(v)*360000 + 0.5))
+	seconds := strconv.FormatFloat(float64(h%6000)/100, 'f', -1, 64)
+	if h%6000 < 1000 {
+		seconds = "0" + seconds
+	}
+	return fmt.Sprintf("%0*d:%02d:%v %v", digits, h/360000, h/6000%60, seconds, hemisphere)
+}
+
+// parseDegrees parses degrees given as D, D:M or D:M:S.
+func parseDegrees(s string) (float64, error) {
+	result := 0.0
+	for i, part := range strings.Split(s, ":") {
+		if i > 2 {
+			return 0, fmt.Errorf("too many fields in '%v'", s)
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		result += v / math.Pow(60, float64(i))
+	}
+	return result, nil
+}
+
+// AirspacePolygon returns the lateral limits of the given airspace as a
+// closed polygon, where the last point repeats the first.
+//
+// Arcs (given by radius and angles, or by their start and end points) and
+// circles are expanded with points every resolution degrees, going in the
+// direction given by Segment.Clockwise. Radius values are in nautical miles.
+// Airways are expanded into a corridor of the airway width around their
+// center line, and can not be mixed with other segments. Airspaces with no
+// segments result in an empty polygon.
+func AirspacePolygon(a airspace.Airspace, resolution float64) ([]Coordinate, error) {
+	if resolution <= 0 {
+		return nil, fmt.Errorf("invalid arc resolution :: %v", resolution)
+	}
+	var result, airway []Coordinate
+	width := 0.0
+	for _, s := range a.Segments {
+		switch s.Type {
+		case airspace.Polygon:
+			c, err := ParseCoordinate(s.Coordinate1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, c)
+		case airspace.Circle:
+			center, err := ParseCoordinate(s.X)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, arc(center, s.Radius*NauticalMile, 0, 360, true, resolution)...)
+		case airspace.Arc:
+			center, err := ParseCoordinate(s.X)
+			if err != nil {
+				return nil, err
+			}
+			if s.Coordinate1 == "" {
+				result = append(result, arc(center, s.Radius*NauticalMile,
+					s.AngleStart, s.AngleEnd, s.Clockwise, resolution)...)
+				continue
+			}
+			c1, err := ParseCoordinate(s.Coordinate1)
+			if err != nil {
+				return nil, err
+			}
+			c2, err := ParseCoordinate(s.Coordinate2)
+			if err != nil {
+				return nil, err
+			}
+			points := arc(center, Distance(center.Latitude, center.Longitude, c1.Latitude, c1.Longitude),
+				Bearing(center.Latitude, center.Longitude, c1.Latitude, c1.Longitude),
+				Bearing(center.Latitude, center.Longitude, c2.Latitude, c2.Longitude), s.Clockwise, resolution)
+			// the arc ends exactly at the given points
+			points[0], points[len(points)-1] = c1, c2
+			result = append(result, points...)
+		case airspace.Airway:
+			c, err := ParseCoordinate(s.Coordinate1)
+			if err != nil {
+				return nil, err
+			}
+			airway, width = append(airway, c), s.W
+		default:
+			return nil, fmt.Errorf("unsupported segment type :: %v", s.Type)
+		}
+	}
+	if len(airway) > 0 {
+		if len(result) > 0 || len(airway) < 2 || width <= 0 {
+			return nil, fmt.Errorf("invalid airway in airspace '%v'", a.Name)
+		}
+		result = corridor(airway, width*NauticalMile/2)
+	}
+	if len(result) > 0 && result[0] != result[len(result)-1] {
+		result = append(result, result[0])
+	}
+	return result, nil
+}
+
+// arc returns the points of an arc around center, with radius in meters and
+// the start and end bearings in degrees. Equal start and end bearings give a
+// full circle.
+func arc(center Coordinate, radius, start, end float64, clockwise bool, resolution float64) []Coordinate {
+	sweep := math.Mod(end-start+720, 360)
+	if !clockwise {
+		sweep = -math.Mod(start-end+720, 360)
+	}
+	if sweep == 0 {
+		sweep = 360
+	}
+	// tolerate rounding in bearings computed from coordinates
+	steps := int(math.Ceil(math.Abs(sweep)/resolution - 1e-6))
+	result := make([]Coordinate, steps+1)
+	for i := range result {
+		result[i].Latitude, result[i].Longitude = Destination(center.Latitude, center.Longitude,
+			start+sweep*float64(i)/float64(steps), radius)
+	}
+	if math.Abs(sweep) == 360 {
+		result[steps] = result[0]
+	}
+	return result
+}
+
+// corridor returns the points around the given line, at the given distance
+// (m) on each side. Offsets at inner points follow the mean direction of the
+// adjacent legs.
+func corridor(line []Coordinate, distance float64) []Coordinate {
+	n := len(line)
+	left, right := make([]Coordinate, n), make([]Coordinate, n)
+	for i, c := range line {
+		var bearing float64
+		switch i {
+		case 0:
+			bearing = Bearing(c.Latitude, c.Longitude, line[1].Latitude, line[1].Longitude)
+		case n - 1:
+			bearing = Bearing(line[i-1].Latitude, line[i-1].Longitude, c.Latitude, c.Longitude)
+		default:
+			in := Bearing(line[i-1].Latitude, line[i-1].Longitude, c.Latitude, c.Longitude)
+			out := Bearing(c.Latitude, c.Longitude, line[i+1].Latitude, line[i+1].Longitude)
+			bearing = in + math.Remainder(out-in, 360)/2
+		}
+		left[i].Latitude, left[i].Longitude = Destination(c.Latitude, c.Longitude, bearing-90, distance)
+		right[n-1-i].Latitude, right[n-1-i].Longitude = Destination(c.Latitude, c.Longitude, bearing+90, distance)
+	}
+	return append(left, right...)
+}
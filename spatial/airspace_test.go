@@ -0,0 +1,288 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package spatial
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/rochaporto/ezgliding/airspace"
+	"github.com/rochaporto/ezgliding/openair"
+)
+
+var center = Coordinate{Latitude: 46.2, Longitude: 6.1}
+
+// coordinate returns the segment coordinate at the given bearing (deg) and
+// distance (NM) from center.
+func coordinate(bearing, nm float64) string {
+	lat, lon := Destination(center.Latitude, center.Longitude, bearing, nm*NauticalMile)
+	return fmt.Sprintf("%d:%06.3f N %03d:%06.3f E", int(lat), (lat-math.Floor(lat))*60,
+		int(lon), (lon-math.Floor(lon))*60)
+}
+
+func TestParseCoordinate(t *testing.T) {
+	tests := []struct {
+		s string
+		c Coordinate
+	}{
+		{"44:16:44 N 000:28:29 E", Coordinate{44.27889, 0.47472}},
+		{"51:30:00 N 3:00:00 E", Coordinate{51.5, 3}},
+		{"44:16.5 S 000:28.5 W", Coordinate{-44.275, -0.475}},
+		{"44:16:44N 000:28:29E", Coordinate{44.27889, 0.47472}},
+		{"46 n 6 e", Coordinate{46, 6}},
+	}
+	for _, test := range tests {
+		c, err := ParseCoordinate(test.s)
+		if err != nil {
+			t.Errorf("%v :: failed to parse :: %v", test.s, err)
+			continue
+		}
+		if math.Abs(c.Latitude-test.c.Latitude) > 1e-4 || math.Abs(c.Longitude-test.c.Longitude) > 1e-4 {
+			t.Errorf("%v :: expected %+v got %+v", test.s, test.c, c)
+		}
+	}
+	for _, s := range []string{"", "44:16:44 N", "44:16:44 X 000:28:29 E", "44:16:44:1 N 000:28:29 E",
+		"44::16 N 000:28:29 E"} {
+		if _, err := ParseCoordinate(s); err == nil {
+			t.Errorf("%v :: expected error got none", s)
+		}
+	}
+}
+
+func TestCoordinateString(t *testing.T) {
+	tests := map[string]Coordinate{
+		"44:16:44 N 000:28:29 E":      {44.278888889, 0.474722222},
+		"44:16:30 S 000:28:30 W":      {-44.275, -0.475},
+		"46:00:00 N 006:00:00 E":      {45.9999999, 5.9999999},
+		"46:00:09.5 N 120:00:00.25 E": {46.002638889, 120.000069444},
+	}
+	for s, c := range tests {
+		if c.String() != s {
+			t.Errorf("expected %v got %v", s, c.String())
+		}
+		parsed, err := ParseCoordinate(c.String())
+		if err != nil || math.Abs(parsed.Latitude-c.Latitude) > 1e-5 || math.Abs(parsed.Longitude-c.Longitude) > 1e-5 {
+			t.Errorf("%v :: round trip failed :: %+v %v", s, parsed, err)
+		}
+	}
+}
+
+type airspacePolygonTest struct {
+	t        string
+	segments []airspace.Segment
+	// radius (NM) of all points, 0 to skip the check
+	radius float64
+	// expected bearing from center of the point in the middle of the
+	// polygon (index len/2), -1 to skip the check
+	middle float64
+	points int
+}
+
+var airspacePolygonTests = []airspacePolygonTest{
+	{
+		t:        "circle",
+		segments: []airspace.Segment{{Type: airspace.Circle, X: coordinate(0, 0), Radius: 2}},
+		radius:   2, middle: 180, points: 73,
+	},
+	{
+		t: "da clockwise",
+		segments: []airspace.Segment{{Type: airspace.Arc, X: coordinate(0, 0), Radius: 2,
+			AngleStart: 350, AngleEnd: 10, Clockwise: true}},
+		radius: 2, middle: 5, points: 6,
+	},
+	{
+		t: "da counter clockwise",
+		segments: []airspace.Segment{{Type: airspace.Arc, X: coordinate(0, 0), Radius: 2,
+			AngleStart: 350, AngleEnd: 10}},
+		radius: 2, middle: 175, points: 70,
+	},
+	{
+		t: "db clockwise",
+		segments: []airspace.Segment{{Type: airspace.Arc, X: coordinate(0, 0), Clockwise: true,
+			Coordinate1: coordinate(90, 2), Coordinate2: coordinate(270, 2)}},
+		radius: 2, middle: 185, points: 38,
+	},
+	{
+		t: "db counter clockwise",
+		segments: []airspace.Segment{{Type: airspace.Arc, X: coordinate(0, 0),
+			Coordinate1: coordinate(90, 2), Coordinate2: coordinate(270, 2)}},
+		radius: 2, middle: 357.5, points: 39,
+	},
+	{
+		t: "polygon",
+		segments: []airspace.Segment{{Type: airspace.Polygon, Coordinate1: coordinate(0, 1)},
+			{Type: airspace.Polygon, Coordinate1: coordinate(120, 1)},
+			{Type: airspace.Polygon, Coordinate1: coordinate(240, 1)}},
+		radius: 1, middle: 240, points: 4,
+	},
+	{
+		t: "mixed segments",
+		segments: []airspace.Segment{{Type: airspace.Polygon, Coordinate1: coordinate(270, 2)},
+			{Type: airspace.Polygon, Coordinate1: coordinate(0, 0)},
+			{Type: airspace.Arc, X: coordinate(0, 0), Clockwise: true,
+				Coordinate1: coordinate(90, 2), Coordinate2: coordinate(270, 2)}},
+		middle: 175, points: 39,
+	},
+	{
+		t: "empty", middle: -1, points: 0,
+	},
+}
+
+func TestAirspacePolygon(t *testing.T) {
+	for _, test := range airspacePolygonTests {
+		polygon, err := AirspacePolygon(airspace.Airspace{Segments: test.segments}, DefaultArcResolution)
+		if err != nil {
+			t.Errorf("%v :: failed to expand :: %v", test.t, err)
+			continue
+		}
+		if len(polygon) != test.points {
+			t.Errorf("%v :: expected %v points got %v", test.t, test.points, len(polygon))
+			continue
+		}
+		if len(polygon) > 0 && polygon[0] != polygon[len(polygon)-1] {
+			t.Errorf("%v :: polygon not closed :: %v", test.t, polygon)
+		}
+		for _, c := range polygon {
+			d := Distance(center.Latitude, center.Longitude, c.Latitude, c.Longitude)
+			if test.radius > 0 && math.Abs(d-test.radius*NauticalMile) > 5 {
+				t.Errorf("%v :: expected radius %v got %v", test.t, test.radius*NauticalMile, d)
+				break
+			}
+		}
+		if test.middle < 0 {
+			continue
+		}
+		c := polygon[len(polygon)/2]
+		b := Bearing(center.Latitude, center.Longitude, c.Latitude, c.Longitude)
+		if math.Abs(math.Remainder(b-test.middle, 360)) > 0.5 {
+			t.Errorf("%v :: expected middle bearing %v got %v", test.t, test.middle, b)
+		}
+	}
+}
+
+func TestAirspacePolygonResolution(t *testing.T) {
+	a := airspace.Airspace{Segments: []airspace.Segment{{Type: airspace.Circle, X: coordinate(0, 0), Radius: 2}}}
+	for resolution, points := range map[float64]int{1: 361, 10: 37, 7: 53} {
+		polygon, err := AirspacePolygon(a, resolution)
+		if err != nil || len(polygon) != points {
+			t.Errorf("resolution %v :: expected %v points got %v :: %v", resolution, points, len(polygon), err)
+		}
+	}
+	if _, err := AirspacePolygon(a, 0); err == nil {
+		t.Errorf("expected error for zero resolution got none")
+	}
+}
+
+func TestAirspacePolygonAirway(t *testing.T) {
+	a := airspace.Airspace{Segments: []airspace.Segment{
+		{Type: airspace.Airway, W: 2, Coordinate1: coordinate(270, 2)},
+		{Type: airspace.Airway, W: 2, Coordinate1: coordinate(0, 0)},
+		{Type: airspace.Airway, W: 2, Coordinate1: coordinate(90, 2)}}}
+	polygon, err := AirspacePolygon(a, DefaultArcResolution)
+	if err != nil {
+		t.Fatalf("failed to expand :: %v", err)
+	}
+	if len(polygon) != 7 || polygon[0] != polygon[6] {
+		t.Fatalf("expected closed polygon of 7 points got %v", polygon)
+	}
+	// the points beside the center, on the left and right of the airway
+	for i, bearing := range map[int]float64{1: 0, 4: 180} {
+		c := polygon[i]
+		d := Distance(center.Latitude, center.Longitude, c.Latitude, c.Longitude)
+		b := Bearing(center.Latitude, center.Longitude, c.Latitude, c.Longitude)
+		if math.Abs(d-NauticalMile) > 5 || math.Abs(math.Remainder(b-bearing, 360)) > 0.5 {
+			t.Errorf("expected point %v at %v deg 1 NM got %v deg %v m", i, bearing, b, d)
+		}
+	}
+}
+
+func TestAirspacePolygonInvalid(t *testing.T) {
+	tests := [][]airspace.Segment{
+		{{Type: airspace.Polygon, Coordinate1: "invalid"}},
+		{{Type: airspace.Circle, X: "", Radius: 2}},
+		{{Type: airspace.Arc, X: coordinate(0, 0), Coordinate1: coordinate(90, 2), Coordinate2: "46 N"}},
+		{{Type: airspace.SegmentType(10)}},
+		{{Type: airspace.Airway, W: 2, Coordinate1: coordinate(0, 0)}},
+		{{Type: airspace.Airway, Coordinate1: coordinate(0, 0)}, {Type: airspace.Airway, Coordinate1: coordinate(90, 2)}},
+		{{Type: airspace.Airway, W: 2, Coordinate1: coordinate(0, 0)}, {Type: airspace.Airway, W: 2, Coordinate1: "invalid"}},
+		{{Type: airspace.Airway, W: 2, Coordinate1: coordinate(0, 0)}, {Type: airspace.Airway, W: 2, Coordinate1: coordinate(90, 2)},
+			{Type: airspace.Polygon, Coordinate1: coordinate(180, 2)}},
+	}
+	for i, test := range tests {
+		if _, err := AirspacePolygon(airspace.Airspace{Segments: test}, DefaultArcResolution); err == nil {
+			t.Errorf("%v :: expected error got none", i)
+		}
+	}
+}
+
+// ringArea returns the signed area (km2) of the ring in a local plane
+// projection, negative for clockwise rings.
+func ringArea(ring []Coordinate) float64 {
+	k := math.Cos(Radians(ring[0].Latitude))
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i].Longitude*k*ring[i+1].Latitude - ring[i+1].Longitude*k*ring[i].Latitude
+	}
+	return area / 2 * math.Pow(2*math.Pi*EarthRadius/1000/360, 2)
+}
+
+func TestAirspacePolygonDefaultDirection(t *testing.T) {
+	content, err := ioutil.ReadFile("../openair/test-airspace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	airspaces, err := openair.Parse(content)
+	if err != nil {
+		t.Fatalf("failed to parse :: %v", err)
+	}
+	var a airspace.Airspace
+	for _, v := range airspaces {
+		if v.Name == "CTR Agen 121.3" {
+			a = v
+		}
+	}
+	// two arcs without V D, each of about 94 deg
+	if len(a.Segments) != 8 || a.Segments[2].Type != airspace.Arc || !a.Segments[6].Clockwise {
+		t.Fatalf("expected 8 segments with clockwise arcs got %+v", a.Segments)
+	}
+	ring, err := AirspacePolygon(a, DefaultArcResolution)
+	if err != nil {
+		t.Fatalf("failed to expand :: %v", err)
+	}
+	if len(ring) != 42 {
+		t.Errorf("expected 42 points got %v", len(ring))
+	}
+	// the ring goes once around the arc center, clockwise
+	x, _ := ParseCoordinate(a.Segments[2].X)
+	sweep := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		b1 := Bearing(x.Latitude, x.Longitude, ring[i].Latitude, ring[i].Longitude)
+		b2 := Bearing(x.Latitude, x.Longitude, ring[i+1].Latitude, ring[i+1].Longitude)
+		sweep += math.Remainder(b2-b1, 360)
+	}
+	if math.Abs(sweep-360) > 1e-6 {
+		t.Errorf("expected sweep of 360 deg around the center got %v", sweep)
+	}
+	if area := ringArea(ring); area > -500 || area < -700 {
+		t.Errorf("expected clockwise ring of about 600 km2 got %v", area)
+	}
+}
@@ -25,10 +25,15 @@ package spatial
 
 import (
 	"errors"
+	"fmt"
+	"image/color"
+	"math"
 	"strconv"
+	"time"
 
 	"github.com/paulmach/go.geojson"
 	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
 	"github.com/rochaporto/ezgliding/waypoint"
 )
 
@@ -80,11 +85,91 @@ func DMD2Decimal(dmd string) float64 {
 	return r
 }
 
+// Decimal2DMD converts the given decimal coordinate to DMD (deg,min,decimalmin)
+// format, as used in IGC files. This is the reverse of DMD2Decimal.
+// Latitudes result in DDMMmmm[NS], longitudes in DDDMMmmm[EW].
+func Decimal2DMD(decimal float64, latitude bool) string {
+	hemisphere := 'N'
+	if latitude && decimal < 0 {
+		hemisphere = 'S'
+	} else if !latitude {
+		hemisphere = 'E'
+		if decimal < 0 {
+			hemisphere = 'W'
+		}
+	}
+	// thousandths of minutes
+	m := int64(math.Floor(math.Abs(decimal)*60000 + 0.5))
+	if latitude {
+		return fmt.Sprintf("%02d%05d%c", m/60000, m%60000, hemisphere)
+	}
+	return fmt.Sprintf("%03d%05d%c", m/60000, m%60000, hemisphere)
+}
+
+// EarthRadius is the mean earth radius, in meters.
+const EarthRadius = 6371000.0
+
+// Distance returns the great circle distance (in meters) between the two
+// given points, in decimal degrees.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := Radians(lat1), Radians(lat2)
+	dphi, dlambda := Radians(lat2-lat1), Radians(lon2-lon1)
+	a := math.Sin(dphi/2)*math.Sin(dphi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dlambda/2)*math.Sin(dlambda/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing returns the initial bearing (in degrees clockwise from north,
+// between 0 and 360) when going from the first to the second point.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := Radians(lat1), Radians(lat2)
+	dlambda := Radians(lon2 - lon1)
+	y := math.Sin(dlambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlambda)
+	return math.Mod(Degrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the point at the given distance (in meters) and
+// bearing (in degrees) from the given point.
+func Destination(lat, lon, bearing, distance float64) (float64, float64) {
+	phi1, lambda1, theta := Radians(lat), Radians(lon), Radians(bearing)
+	delta := distance / EarthRadius
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1),
+		math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return Degrees(phi2), math.Mod(Degrees(lambda2)+540, 360) - 180
+}
+
+// Radians converts the given angle from degrees to radians.
+func Radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+// Degrees converts the given angle from radians to degrees.
+func Degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
+
+// InPolygon checks if the given position is inside the polygon, using ray
+// casting on the decimal degree coordinates.
+func InPolygon(lat, lon float64, polygon []Coordinate) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lon < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
 // Struct2GeoJSON returns a collection of GeoJSON objects from the given structs.
 // The given array can have distinct types (Airfield, Waypoint, Airspace) and the
 // resulting GeoJSON will contain all fields as properties, and an additional one
 // specifying the Go type (ex: "Go": "Airfield"), used later to unmarshal.
-// Airfield and Waypoint result in Point geometries, Airspace in LineString.
+// Airfield and Waypoint result in Point geometries, Airspace in Polygon.
+// Airspaces with no area are skipped.
 func Struct2GeoJSON(features []interface{}) (*geojson.FeatureCollection, error) {
 	result := geojson.NewFeatureCollection()
 	for _, e := range features {
@@ -96,8 +181,15 @@ func Struct2GeoJSON(features []interface{}) (*geojson.FeatureCollection, error)
 			f = airfield2GeoJSON([]airfield.Airfield{e.(airfield.Airfield)})
 		case waypoint.Waypoint:
 			f = waypoint2GeoJSON([]waypoint.Waypoint{e.(waypoint.Waypoint)})
+		case airspace.Airspace:
+			var err error
+			if f, err = airspace2GeoJSON([]airspace.Airspace{e.(airspace.Airspace)}); err != nil {
+				return nil, err
+			}
+		}
+		for _, g := range f {
+			result.AddFeature(g)
 		}
-		result.AddFeature(f[0])
 	}
 	return result, nil
 }
@@ -141,6 +233,57 @@ func waypoint2GeoJSON(waypoints []waypoint.Waypoint) []*geojson.Feature {
 	return result
 }
 
+// airspace2GeoJSON converts the given airspace to GeoJSON format, with the
+// lateral limits expanded by AirspacePolygon. Colors are kept as lists of
+// their RGBA components.
+// Airspaces with less than 3 distinct points, which can not make a valid
+// polygon, are skipped.
+func airspace2GeoJSON(airspaces []airspace.Airspace) ([]*geojson.Feature, error) {
+	result := []*geojson.Feature{}
+	for _, airspace := range airspaces {
+		polygon, err := AirspacePolygon(airspace, DefaultArcResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert airspace '%v' :: %v", airspace.Name, err)
+		}
+		if len(polygon) < 4 {
+			continue
+		}
+		ring := [][]float64{}
+		for _, c := range polygon {
+			ring = append(ring, []float64{c.Longitude, c.Latitude})
+		}
+		g := geojson.NewPolygonFeature([][][]float64{ring})
+		g.SetProperty("ID", airspace.ID)
+		g.SetProperty("Date", airspace.Date.Format(time.RFC3339Nano))
+		g.SetProperty("Class", string(airspace.Class))
+		g.SetProperty("Type", airspace.Type)
+		g.SetProperty("Name", airspace.Name)
+		g.SetProperty("Ceiling", airspace.Ceiling)
+		g.SetProperty("Floor", airspace.Floor)
+		g.SetProperty("Frequency", airspace.Frequency)
+		g.SetProperty("Station", airspace.Station)
+		g.SetProperty("Activation", airspace.Activation)
+		g.SetProperty("Label", airspace.Label)
+		g.SetProperty("PenStyle", int(airspace.Pen.Style))
+		g.SetProperty("PenWidth", airspace.Pen.Width)
+		g.SetProperty("PenColor", color2GeoJSON(airspace.Pen.Color))
+		g.SetProperty("PenInsideColor", color2GeoJSON(airspace.Pen.InsideColor))
+		g.SetProperty("Update", airspace.Update.Format(time.RFC3339Nano))
+		g.SetProperty("Go", "Airspace")
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+// color2GeoJSON returns the RGBA components of the given color, or nil.
+func color2GeoJSON(c color.Color) []uint32 {
+	if c == nil {
+		return nil
+	}
+	r, g, b, a := c.RGBA()
+	return []uint32{r, g, b, a}
+}
+
 // GeoJSON2Struct returns airfield, waypoint, etc objects from the given GeoJSON.
 // The resulting array contains distinct types (airfield.Airfield, waypoint.Waypoint,
 // airspace.Airspace, ...) and the unmarshaling is done with the same rules as
@@ -159,6 +302,10 @@ func GeoJSON2Struct(json string) ([]interface{}, error) {
 			o = feature2Airfield(f)
 		case "Waypoint":
 			o = feature2Waypoint(f)
+		case "Airspace":
+			if o, err = feature2Airspace(f); err != nil {
+				return result, err
+			}
 		default:
 			return result, errors.New("geojson feature given not supported")
 		}
@@ -190,3 +337,74 @@ func feature2Waypoint(f *geojson.Feature) waypoint.Waypoint {
 	w.Latitude = f.Geometry.Point[1]
 	return w
 }
+
+// feature2Airspace converts the given feature to an airspace, with one
+// Polygon segment for each point of the feature polygon.
+func feature2Airspace(f *geojson.Feature) (airspace.Airspace, error) {
+	a := airspace.Airspace{
+		ID: f.PropertyMustString("ID"), Type: f.PropertyMustString("Type"), Name: f.PropertyMustString("Name"),
+		Ceiling: f.PropertyMustString("Ceiling"), Floor: f.PropertyMustString("Floor"),
+		Frequency: f.PropertyMustFloat64("Frequency"), Station: f.PropertyMustString("Station"),
+		Pen: airspace.Pen{
+			Style: airspace.PenStyle(f.PropertyMustInt("PenStyle")), Width: f.PropertyMustInt("PenWidth"),
+			Color: feature2Color(f, "PenColor"), InsideColor: feature2Color(f, "PenInsideColor"),
+		},
+	}
+	if class := f.PropertyMustString("Class"); len(class) > 0 {
+		a.Class = class[0]
+	}
+	var err error
+	if a.Date, err = time.Parse(time.RFC3339Nano, f.PropertyMustString("Date", "0001-01-01T00:00:00Z")); err != nil {
+		return a, fmt.Errorf("invalid airspace date :: %v", err)
+	}
+	if a.Update, err = time.Parse(time.RFC3339Nano, f.PropertyMustString("Update", "0001-01-01T00:00:00Z")); err != nil {
+		return a, fmt.Errorf("invalid airspace update :: %v", err)
+	}
+	a.Activation = feature2Strings(f, "Activation")
+	a.Label = feature2Strings(f, "Label")
+	if f.Geometry == nil || !f.Geometry.IsPolygon() {
+		return a, errors.New("airspace geometry is not a polygon")
+	}
+	if len(f.Geometry.Polygon) > 0 {
+		ring := f.Geometry.Polygon[0]
+		for i, c := range ring {
+			if len(c) < 2 {
+				return a, fmt.Errorf("invalid airspace coordinate :: %v", c)
+			}
+			// the last point closes the polygon
+			if i == len(ring)-1 && i > 0 && c[0] == ring[0][0] && c[1] == ring[0][1] {
+				break
+			}
+			a.Segments = append(a.Segments, airspace.Segment{Type: airspace.Polygon,
+				Coordinate1: Coordinate{Latitude: c[1], Longitude: c[0]}.String()})
+		}
+	}
+	return a, nil
+}
+
+// feature2Strings returns the strings in the given feature property, or nil.
+func feature2Strings(f *geojson.Feature, key string) []string {
+	var result []string
+	if values, ok := f.Properties[key].([]interface{}); ok {
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// feature2Color returns the color in the given feature property, or nil.
+func feature2Color(f *geojson.Feature, key string) color.Color {
+	values, ok := f.Properties[key].([]interface{})
+	if !ok || len(values) != 4 {
+		return nil
+	}
+	var rgba [4]uint16
+	for i, v := range values {
+		n, _ := v.(float64)
+		rgba[i] = uint16(n)
+	}
+	return color.RGBA64{R: rgba[0], G: rgba[1], B: rgba[2], A: rgba[3]}
+}
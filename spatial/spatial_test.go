@@ -20,10 +20,14 @@
 package spatial
 
 import (
+	"image/color"
+	"math"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rochaporto/ezgliding/airfield"
+	"github.com/rochaporto/ezgliding/airspace"
 	"github.com/rochaporto/ezgliding/waypoint"
 )
 
@@ -125,6 +129,86 @@ func TestDMD2Decimal(t *testing.T) {
 	}
 }
 
+func TestDecimal2DMD(t *testing.T) {
+	for _, test := range dmd2DecimalTests {
+		if test.in[0] == 'N' || test.in[0] == 'S' || test.in[0] == 'E' || test.in[0] == 'W' {
+			continue
+		}
+		latitude := test.in[len(test.in)-1] == 'N' || test.in[len(test.in)-1] == 'S'
+		result := Decimal2DMD(test.r, latitude)
+		if result != test.in {
+			t.Errorf("test %v failed, expected %v got %v", test.t, test.in, result)
+		}
+	}
+	if r := Decimal2DMD(-0.99999999, true); r != "0100000S" {
+		t.Errorf("rounding to the next degree failed, got %v", r)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// one degree of latitude
+	if d := Distance(46, 6, 47, 6); math.Abs(d-111194.9) > 0.1 {
+		t.Errorf("expected 111194.9 got %v", d)
+	}
+	if d := Distance(46, 6, 46, 6); d != 0 {
+		t.Errorf("expected 0 got %v", d)
+	}
+}
+
+func TestBearing(t *testing.T) {
+	tests := [][5]float64{
+		{46, 6, 47, 6, 0}, {46, 6, 46, 7, 89.64}, {46, 6, 45, 6, 180}, {46, 6, 46, 5, 270.36},
+	}
+	for _, test := range tests {
+		if b := Bearing(test[0], test[1], test[2], test[3]); math.Abs(b-test[4]) > 0.01 {
+			t.Errorf("expected bearing %v got %v", test[4], b)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	for _, bearing := range []float64{0, 45, 90, 200, 359} {
+		lat, lon := Destination(46.2, 6.4, bearing, 25000)
+		if d := Distance(46.2, 6.4, lat, lon); math.Abs(d-25000) > 0.01 {
+			t.Errorf("expected distance 25000 got %v", d)
+		}
+		if b := Bearing(46.2, 6.4, lat, lon); math.Abs(math.Remainder(b-bearing, 360)) > 0.01 {
+			t.Errorf("expected bearing %v got %v", bearing, b)
+		}
+	}
+}
+
+func TestRadians(t *testing.T) {
+	for d, r := range map[float64]float64{0: 0, 90: math.Pi / 2, -180: -math.Pi, 360: 2 * math.Pi} {
+		if math.Abs(Radians(d)-r) > 1e-12 || math.Abs(Degrees(r)-d) > 1e-12 {
+			t.Errorf("expected %v deg to be %v rad got %v and %v", d, r, Radians(d), Degrees(r))
+		}
+	}
+}
+
+func TestInPolygon(t *testing.T) {
+	// a concave polygon, with a notch on the north side
+	polygon := []Coordinate{{46, 6}, {46, 7}, {47, 7}, {47, 6.6}, {46.5, 6.5}, {47, 6.4}, {47, 6}}
+	tests := []struct {
+		lat, lon float64
+		r        bool
+	}{
+		{46.2, 6.5, true},
+		{46.8, 6.2, true},
+		{46.8, 6.5, false},
+		{45.9, 6.5, false},
+		{46.5, 7.1, false},
+	}
+	for _, test := range tests {
+		if r := InPolygon(test.lat, test.lon, polygon); r != test.r {
+			t.Errorf("%v %v :: expected %v got %v", test.lat, test.lon, test.r, r)
+		}
+	}
+	if InPolygon(46.5, 6.5, nil) {
+		t.Errorf("expected no point inside an empty polygon")
+	}
+}
+
 type Struct2GeoJSONTest struct {
 	t  string
 	in []interface{}
@@ -164,6 +248,24 @@ var struct2GeoJSONTests = []Struct2GeoJSONTest{
 		},
 		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[6.463,46.27]},"properties":{"Catalog":69,"Elevation":1113,"Flags":1032,"Frequency":122.5,"Go":"Airfield","ICAO":"HHHH","ID":"HABER","Length":900,"Name":"HABERE POC69","Region":"FR","Runway":"0119","ShortName":"HABER"}},{"type":"Feature","geometry":{"type":"Point","coordinates":[8.415,46.572]},"properties":{"Description":"FURKAPASS PASSHOEHE","Elevation":2432,"Flags":0,"Go":"Waypoint","ID":"FURKAP","Name":"FURKAP","Region":"CH"}}]}`,
 	},
+	Struct2GeoJSONTest{
+		"simple airspace conversion",
+		[]interface{}{
+			airspace.Airspace{
+				ID: "GENEVA", Date: time.Date(2015, 2, 10, 12, 0, 0, 0, time.UTC), Class: 'C',
+				Type: "TMA", Name: "TMA GENEVE", Ceiling: "FL195", Floor: "1500ft AGL", Frequency: 120.3,
+				Station: "Geneva Approach", Activation: []string{"NONE"}, Label: []string{"46:15:00 N 006:10:00 E"},
+				Segments: []airspace.Segment{
+					{Type: airspace.Polygon, Coordinate1: "46:12:00 N 006:06:00 E"},
+					{Type: airspace.Polygon, Coordinate1: "46:18:30 N 006:06:00 E"},
+					{Type: airspace.Polygon, Coordinate1: "46:18:30 N 006:20:15.5 E"},
+				},
+				Pen: airspace.Pen{Style: airspace.Dash, Width: 2, Color: color.RGBA64{R: 255, A: 1},
+					InsideColor: color.RGBA64{}},
+			},
+		},
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[6.1,46.2],[6.1,46.30833333333333],[6.337638888888889,46.30833333333333],[6.1,46.2]]]},"properties":{"Activation":["NONE"],"Ceiling":"FL195","Class":"C","Date":"2015-02-10T12:00:00Z","Floor":"1500ft AGL","Frequency":120.3,"Go":"Airspace","ID":"GENEVA","Label":["46:15:00 N 006:10:00 E"],"Name":"TMA GENEVE","PenColor":[255,0,0,1],"PenInsideColor":[0,0,0,0],"PenStyle":1,"PenWidth":2,"Station":"Geneva Approach","Type":"TMA","Update":"0001-01-01T00:00:00Z"}}]}`,
+	},
 }
 
 func TestStruct2GeoJSON(t *testing.T) {
@@ -219,6 +321,62 @@ func TestGeoJSON2StructUnsupported(t *testing.T) {
 	}
 }
 
+func TestAirspace2GeoJSONCircle(t *testing.T) {
+	a := airspace.Airspace{Name: "CTR", Segments: []airspace.Segment{
+		{Type: airspace.Circle, X: "46:12:00 N 006:06:00 E", Radius: 2}}}
+	result, err := Struct2GeoJSON([]interface{}{a})
+	if err != nil {
+		t.Fatalf("failed to convert circle :: %v", err)
+	}
+	if ring := result.Features[0].Geometry.Polygon[0]; len(ring) != 73 {
+		t.Errorf("expected 73 points got %v", len(ring))
+	}
+	a.Segments[0].X = "invalid"
+	if _, err := Struct2GeoJSON([]interface{}{a}); err == nil {
+		t.Errorf("expected error for invalid center got success")
+	}
+}
+
+func TestAirspace2GeoJSONDegenerate(t *testing.T) {
+	valid := airspace.Airspace{Name: "CTR", Segments: []airspace.Segment{
+		{Type: airspace.Circle, X: "46:12:00 N 006:06:00 E", Radius: 2}}}
+	tests := []airspace.Airspace{
+		{Name: "no segments"},
+		{Name: "single point", Segments: []airspace.Segment{
+			{Type: airspace.Polygon, Coordinate1: "46:12:00 N 006:06:00 E"}}},
+		{Name: "two points", Segments: []airspace.Segment{
+			{Type: airspace.Polygon, Coordinate1: "46:12:00 N 006:06:00 E"},
+			{Type: airspace.Polygon, Coordinate1: "46:13:00 N 006:06:00 E"}}},
+	}
+	for _, test := range tests {
+		result, err := Struct2GeoJSON([]interface{}{test, valid})
+		if err != nil {
+			t.Errorf("%v :: failed to convert :: %v", test.Name, err)
+			continue
+		}
+		if len(result.Features) != 1 || result.Features[0].Properties["Name"] != "CTR" {
+			t.Errorf("%v :: expected only the valid airspace got %v features", test.Name, len(result.Features))
+		}
+	}
+	unknown := airspace.Airspace{Name: "unknown segment", Segments: []airspace.Segment{{Type: airspace.SegmentType(10)}}}
+	if _, err := Struct2GeoJSON([]interface{}{unknown}); err == nil {
+		t.Errorf("expected error for unknown segment type got success")
+	}
+}
+
+func TestGeoJSON2StructAirspaceInvalid(t *testing.T) {
+	tests := []string{
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[8.415,46.572]},"properties":{"Go":"Airspace"}}]}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[8.415]]]},"properties":{"Go":"Airspace"}}]}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Polygon","coordinates":[]},"properties":{"Go":"Airspace","Date":"yesterday"}}]}`,
+	}
+	for i, test := range tests {
+		if _, err := GeoJSON2Struct(test); err == nil {
+			t.Errorf("%v :: expected error got success", i)
+		}
+	}
+}
+
 func TestGeoJSON2StructInvalid(t *testing.T) {
 	_, err := GeoJSON2Struct(`{"type":invalid"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[8.415,46.572]},"properties":{}}]}`)
 	if err == nil {
@@ -0,0 +1,296 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package task
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+// Kind is the kind of a competition task.
+type Kind int
+
+const (
+	// Racing is a task through each turnpoint observation zone.
+	Racing Kind = iota
+	// AAT is an assigned area task, where pilots choose where to turn
+	// inside each area and fly for at least the minimum task time.
+	AAT
+)
+
+func (k Kind) String() string {
+	if k == AAT {
+		return "aat"
+	}
+	return "racing"
+}
+
+// TaskPoint is a point of a competition task, with its observation zone
+// (or area, for assigned area tasks).
+type TaskPoint struct {
+	Waypoint waypoint.Waypoint
+	Zone     Zone
+}
+
+// Task is a competition task, independent of any declaration in the flight.
+type Task struct {
+	Kind       Kind
+	Start      TaskPoint
+	Turnpoints []TaskPoint
+	Finish     TaskPoint
+	// MinTime is the minimum task time of assigned area tasks. Finishing
+	// earlier counts as taking MinTime for the speed.
+	MinTime time.Duration
+	// StartOpen is when starts are allowed, zero for any time, and
+	// MaxStartAltitude the max altitude (m) at start, zero for no limit.
+	StartOpen        time.Time
+	MaxStartAltitude float64
+	// FinishClose is the time after which finishes are not valid, zero for
+	// any time, and MinFinishAltitude the min altitude (m) at finish.
+	FinishClose       time.Time
+	MinFinishAltitude float64
+}
+
+// maxCandidates is the max number of fixes considered in each area when
+// maximizing the scored distance.
+const maxCandidates = 500
+
+// zones returns the placed observation zones of the task points.
+func (t Task) zones() []zone {
+	tps := append([]TaskPoint{t.Start}, t.Turnpoints...)
+	tps = append(tps, t.Finish)
+	points := make([]flight.Point, len(tps))
+	for i, tp := range tps {
+		points[i] = flight.NewPoint()
+		points[i].Latitude, points[i].Longitude = tp.Waypoint.Latitude, tp.Waypoint.Longitude
+		points[i].Description = tp.Waypoint.Name
+	}
+	zones := make([]zone, len(tps))
+	for i, tp := range tps {
+		var in, out *flight.Point
+		if i > 0 {
+			in = &points[i-1]
+		}
+		if i < len(points)-1 {
+			out = &points[i+1]
+		}
+		zones[i] = newZone(tp.Zone, points[i], in, out)
+	}
+	return zones
+}
+
+// NominalDistance returns the distance (km) through the task point centers.
+func (t Task) NominalDistance() float64 {
+	d := 0.0
+	zones := t.zones()
+	for i := 1; i < len(zones); i++ {
+		d += distance(zones[i-1].center, zones[i].center)
+	}
+	return d
+}
+
+// MinDistance returns the shortest distance (km) through the task zones.
+func (t Task) MinDistance() float64 {
+	return t.path(math.Min)
+}
+
+// MaxDistance returns the longest distance (km) through the task zones, the
+// nominal distance for racing tasks.
+func (t Task) MaxDistance() float64 {
+	if t.Kind == Racing {
+		return t.NominalDistance()
+	}
+	return t.path(math.Max)
+}
+
+// path returns the shortest or longest (given by best) distance (km) through
+// points in the boundaries of the task zones. Start and finish are always
+// taken at their centers.
+func (t Task) path(best func(float64, float64) float64) float64 {
+	zones := t.zones()
+	candidates := make([][]flight.Point, len(zones))
+	for i, z := range zones {
+		if i == 0 || i == len(zones)-1 {
+			candidates[i] = []flight.Point{z.center}
+		} else {
+			candidates[i] = z.boundary()
+		}
+	}
+	return bestPath(candidates, best, nil)
+}
+
+// bestPath returns the best (given by best) distance (km) going through one
+// point of each candidate set, in order. The indices of the points chosen are
+// set in chosen, if not nil.
+func bestPath(candidates [][]flight.Point, best func(float64, float64) float64, chosen []int) float64 {
+	n := len(candidates)
+	dist := make([][]float64, n)
+	prev := make([][]int, n)
+	dist[0] = make([]float64, len(candidates[0]))
+	for k := 1; k < n; k++ {
+		dist[k] = make([]float64, len(candidates[k]))
+		prev[k] = make([]int, len(candidates[k]))
+		for j, p := range candidates[k] {
+			for i, q := range candidates[k-1] {
+				d := dist[k-1][i] + distance(q, p)
+				if i == 0 || best(d, dist[k][j]) == d {
+					dist[k][j], prev[k][j] = d, i
+				}
+			}
+		}
+	}
+	end := 0
+	for j, d := range dist[n-1] {
+		if best(d, dist[n-1][end]) == d {
+			end = j
+		}
+	}
+	if chosen != nil {
+		chosen[n-1] = end
+		for k := n - 1; k > 0; k-- {
+			chosen[k-1] = prev[k][chosen[k]]
+		}
+	}
+	return dist[n-1][end]
+}
+
+// distance returns the distance (km) between the given points.
+func distance(p1, p2 flight.Point) float64 {
+	return spatial.Distance(p1.Latitude, p1.Longitude, p2.Latitude, p2.Longitude) / 1000
+}
+
+// Score checks the given flight against the task and returns the result.
+//
+// Result.Distance is the scored distance: the nominal distance for completed
+// racing tasks, and for assigned area tasks the longest distance through the
+// fixes flown in each area. Incomplete tasks score the distance to the last
+// zone reached, plus the best progress towards the next one. For assigned
+// area tasks the speed uses at least MinTime.
+func (t Task) Score(f flight.Flight) (Result, error) {
+	if len(f.Points) < 2 {
+		return Result{}, errors.New("not enough points to score")
+	}
+	zones := t.zones()
+	r := verify(f.Points, zones, t.valid)
+	if r.Fixes[0] < 0 {
+		r.Distance = 0
+		return r, nil
+	}
+
+	// reached zones, with the fixes counted in each
+	reached := 1
+	for reached < len(zones) && r.Fixes[reached] >= 0 {
+		reached++
+	}
+	candidates := [][]flight.Point{{zones[0].center}}
+	indices := [][]int{{r.Fixes[0]}}
+	for k := 1; k < reached; k++ {
+		if k == len(zones)-1 || t.Kind == Racing {
+			candidates = append(candidates, []flight.Point{zones[k].center})
+			indices = append(indices, []int{r.Fixes[k]})
+			continue
+		}
+		end := len(f.Points)
+		if k+1 < reached {
+			end = r.Fixes[k+1]
+		}
+		var points []flight.Point
+		var idx []int
+		for i := r.Fixes[k]; i < end; i++ {
+			if zones[k].inside(f.Points[i]) {
+				points, idx = append(points, f.Points[i]), append(idx, i)
+			}
+		}
+		if len(points) == 0 {
+			// zones with no inside (lines) count the center
+			points, idx = []flight.Point{zones[k].center}, []int{r.Fixes[k]}
+		}
+		if step := len(points)/maxCandidates + 1; step > 1 {
+			var sampled []flight.Point
+			var sampledIdx []int
+			for i := 0; i < len(points); i += step {
+				sampled, sampledIdx = append(sampled, points[i]), append(sampledIdx, idx[i])
+			}
+			points, idx = sampled, sampledIdx
+		}
+		candidates = append(candidates, points)
+		indices = append(indices, idx)
+	}
+
+	if !r.Completed {
+		// progress towards the next zone center from the last fix reached
+		next := zones[reached].center
+		closest := math.Inf(1)
+		for i := r.Fixes[reached-1]; i < len(f.Points); i++ {
+			closest = math.Min(closest, distance(f.Points[i], next))
+		}
+		var progress []flight.Point
+		for _, p := range candidates[reached-1] {
+			q := p
+			// a virtual point at the distance of the best progress
+			lat, lon := spatial.Destination(p.Latitude, p.Longitude,
+				spatial.Bearing(p.Latitude, p.Longitude, next.Latitude, next.Longitude),
+				math.Max(0, distance(p, next)-closest)*1000)
+			q.Latitude, q.Longitude = lat, lon
+			progress = append(progress, q)
+		}
+		candidates = append(candidates, progress)
+	}
+
+	chosen := make([]int, len(candidates))
+	r.Distance = bestPath(candidates, math.Max, chosen)
+	for k := 1; k < reached && k < len(indices); k++ {
+		r.Fixes[k] = indices[k][chosen[k]]
+	}
+	if r.Completed {
+		elapsed := r.Finish.Sub(r.Start)
+		if t.Kind == AAT && elapsed < t.MinTime {
+			elapsed = t.MinTime
+		}
+		r.Speed = 0
+		if elapsed > 0 {
+			r.Speed = r.Distance / elapsed.Hours()
+		}
+	}
+	return r, nil
+}
+
+// valid checks the start and finish rules for the fix achieving the zone.
+func (t Task) valid(zone int, p flight.Point) bool {
+	alt := float64(p.PressureAltitude)
+	if alt == 0 {
+		alt = float64(p.GNSSAltitude)
+	}
+	if zone == 0 {
+		if !t.StartOpen.IsZero() && p.Time.Before(t.StartOpen) {
+			return false
+		}
+		return t.MaxStartAltitude == 0 || alt <= t.MaxStartAltitude
+	}
+	if !t.FinishClose.IsZero() && p.Time.After(t.FinishClose) {
+		return false
+	}
+	return alt >= t.MinFinishAltitude
+}
@@ -0,0 +1,200 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package task
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/waypoint"
+)
+
+// taskPoint returns a task point at the given distance (km) and bearing from
+// the task start, with the given zone.
+func taskPoint(bearing float64, distance float64, z Zone) TaskPoint {
+	p := point(bearing, distance)
+	return TaskPoint{Waypoint: waypoint.Waypoint{Latitude: p.Latitude, Longitude: p.Longitude}, Zone: z}
+}
+
+var (
+	startLine  = Zone{Type: Line, Radius: 5000}
+	finishLine = Zone{Type: Line, Radius: 500}
+)
+
+// aatTask has a single 20 km area 100 km north of the start.
+func aatTask(area Zone) Task {
+	return Task{
+		Kind:       AAT,
+		Start:      taskPoint(0, 0, startLine),
+		Turnpoints: []TaskPoint{taskPoint(0, 100, area)},
+		Finish:     taskPoint(0, 0, finishLine),
+		MinTime:    2 * time.Hour,
+	}
+}
+
+// racingTask is a 180 km triangle with 500 m cylinders.
+func racingTask() Task {
+	cylinder := Zone{Type: Cylinder, Radius: 500}
+	return Task{
+		Kind:       Racing,
+		Start:      taskPoint(0, 0, startLine),
+		Turnpoints: []TaskPoint{taskPoint(0, 60, cylinder), taskPoint(60, 60, cylinder)},
+		Finish:     taskPoint(0, 0, finishLine),
+	}
+}
+
+type distanceTest struct {
+	t                 string
+	task              Task
+	nominal, min, max float64
+}
+
+var distanceTests = []distanceTest{
+	{"racing", racingTask(), 180, 178.5, 180},
+	{"aat cylinder", aatTask(Zone{Type: Cylinder, Radius: 20000}), 200, 160, 240},
+	{"aat sector", aatTask(Zone{Type: Sector, Radius: 20000, Radial1: 315, Radial2: 45}), 200, 200, 240},
+	{"aat polygon", aatTask(Zone{Type: Polygon, Polygon: []flight.Point{
+		point(5.71, 100.5), point(354.29, 100.5), point(354.81, 110.45), point(5.19, 110.45),
+	}}), 200, 200, 220.9},
+}
+
+func TestTaskDistances(t *testing.T) {
+	for _, test := range distanceTests {
+		nominal, min, max := test.task.NominalDistance(), test.task.MinDistance(), test.task.MaxDistance()
+		if math.Abs(nominal-test.nominal) > 0.1 || math.Abs(min-test.min) > 0.5 ||
+			math.Abs(max-test.max) > 0.5 {
+			t.Errorf("%v :: expected %v %v %v got %v %v %v", test.t, test.nominal, test.min,
+				test.max, nominal, min, max)
+		}
+		if min > nominal+0.5 || max < nominal-0.5 {
+			t.Errorf("%v :: nominal distance %v not between %v and %v", test.t, nominal, min, max)
+		}
+	}
+}
+
+type scoreTest struct {
+	t      string
+	task   Task
+	points []flight.Point
+	result Result
+}
+
+// startOpen and finishClose restrict the task to the given times.
+func startOpen(t Task, open time.Time) Task {
+	t.StartOpen = open
+	return t
+}
+
+func finishClose(t Task, close time.Time) Task {
+	t.FinishClose = close
+	return t
+}
+
+var scoreTests = []scoreTest{
+	{
+		t:      "racing",
+		task:   racingTask(),
+		points: path(point(180, 3), point(0, 60), point(60, 60), point(0, 0), point(180, 2)),
+		result: Result{Completed: true, Distance: 180, Speed: 100},
+	},
+	{
+		t:      "racing landed out",
+		task:   racingTask(),
+		points: path(point(180, 3), point(0, 60), point(60, 60), point(30, 30)),
+		result: Result{Reason: "no valid finish", Distance: 150},
+	},
+	{
+		t:      "aat",
+		task:   aatTask(Zone{Type: Cylinder, Radius: 20000}),
+		points: path(point(180, 3), point(0, 115), point(0, 0), point(180, 2)),
+		result: Result{Completed: true, Distance: 230, Speed: 100},
+	},
+	{
+		t:      "aat min time",
+		task:   aatTask(Zone{Type: Cylinder, Radius: 20000}),
+		points: path(point(180, 3), point(0, 85), point(0, 0), point(180, 2)),
+		result: Result{Completed: true, Distance: 170, Speed: 85},
+	},
+	{
+		t:      "aat landed out",
+		task:   aatTask(Zone{Type: Cylinder, Radius: 20000}),
+		points: path(point(180, 3), point(0, 110), point(0, 60)),
+		result: Result{Reason: "no valid finish", Distance: 160},
+	},
+	{
+		t:      "aat area not reached",
+		task:   aatTask(Zone{Type: Cylinder, Radius: 20000}),
+		points: path(point(180, 3), point(0, 50), point(0, 30)),
+		result: Result{Reason: "turnpoint 1 not reached", Distance: 50},
+	},
+	{
+		t:      "start not open",
+		task:   startOpen(racingTask(), base.Add(2*time.Hour)),
+		points: path(point(180, 3), point(0, 60), point(60, 60), point(0, 0), point(180, 2)),
+		result: Result{Reason: "no valid start"},
+	},
+	{
+		t:      "finish closed",
+		task:   finishClose(racingTask(), base.Add(time.Hour)),
+		points: path(point(180, 3), point(0, 60), point(60, 60), point(0, 0), point(180, 2)),
+		result: Result{Reason: "no valid finish", Distance: 180},
+	},
+}
+
+func TestTaskScore(t *testing.T) {
+	for _, test := range scoreTests {
+		f := flight.NewFlight()
+		f.Points = test.points
+		r, err := test.task.Score(f)
+		if err != nil {
+			t.Errorf("%v :: unexpected error :: %v", test.t, err)
+			continue
+		}
+		e := test.result
+		if r.Completed != e.Completed || r.Reason != e.Reason || math.Abs(r.Distance-e.Distance) > 0.5 ||
+			math.Abs(r.Speed-e.Speed) > 1 {
+			t.Errorf("%v :: expected %+v got %+v", test.t, e, r)
+		}
+	}
+}
+
+func TestTaskScoreFixes(t *testing.T) {
+	f := flight.NewFlight()
+	f.Points = path(point(180, 3), point(0, 115), point(0, 0), point(180, 2))
+	r, err := aatTask(Zone{Type: Cylinder, Radius: 20000}).Score(f)
+	if err != nil {
+		t.Fatalf("unexpected error :: %v", err)
+	}
+	// the area fix is the furthest point, 115 km north
+	if len(r.Fixes) != 3 || r.Fixes[0] != 30 || r.Fixes[1] != 1180 {
+		t.Errorf("expected fixes [30 1180 ...] got %v", r.Fixes)
+	}
+	if _, err := aatTask(Zone{}).Score(flight.NewFlight()); err == nil {
+		t.Errorf("expected error with no points")
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if Racing.String() != "racing" || AAT.String() != "aat" {
+		t.Errorf("unexpected kind strings %v %v", Racing, AAT)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+// Package task verifies flight tasks, checking the observation zones of
+// each task point were achieved by the flight.
+//
+// This includes declared tasks (flight.Task) and competition tasks, both
+// racing and assigned area tasks.
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// Options holds the observation zones used in task verification.
+type Options struct {
+	Start     Zone
+	Turnpoint Zone
+	Finish    Zone
+}
+
+// DefaultOptions has a 10 km start line, unlimited FAI sectors and a 1 km
+// finish line.
+var DefaultOptions = Options{
+	Start:     Zone{Type: Line, Radius: 5000},
+	Turnpoint: Zone{Type: FAISector},
+	Finish:    Zone{Type: Line, Radius: 500},
+}
+
+// Result is the result of a task verification.
+type Result struct {
+	Completed bool
+	// Reason says why the task was not completed.
+	Reason string
+	// Fixes holds the indices of the points achieving the start, each
+	// turnpoint and the finish, -1 for those not achieved.
+	Fixes  []int
+	Start  time.Time
+	Finish time.Time
+	// Distance (km) of the task, between the task points.
+	Distance float64
+	// Speed (km/h) of the task, when completed.
+	Speed float64
+}
+
+// Verify checks the flight flew its declared task (flight.Task), using the
+// observation zones in the given options.
+func Verify(f flight.Flight, opts Options) (Result, error) {
+	if isZero(f.Task.Start) || isZero(f.Task.Finish) {
+		return Result{}, errors.New("no task declared")
+	}
+	points := append([]flight.Point{f.Task.Start}, f.Task.Turnpoints...)
+	points = append(points, f.Task.Finish)
+	zones := make([]zone, len(points))
+	for i, p := range points {
+		var in, out *flight.Point
+		z := opts.Turnpoint
+		if i > 0 {
+			in = &points[i-1]
+		} else {
+			z = opts.Start
+		}
+		if i < len(points)-1 {
+			out = &points[i+1]
+		} else {
+			z = opts.Finish
+		}
+		zones[i] = newZone(z, p, in, out)
+	}
+	return verify(f.Points, zones, nil), nil
+}
+
+// isZero checks if the task point has no position.
+func isZero(p flight.Point) bool {
+	return p.Latitude == 0 && p.Longitude == 0
+}
+
+// verify checks the given zones, in order, against the flight points.
+//
+// The start used is the last one before reaching the first turnpoint (or
+// the finish, if there are no turnpoints). When given, valid checks the
+// start (at index 0) and finish fixes.
+func verify(points []flight.Point, zones []zone, valid func(zone int, p flight.Point) bool) Result {
+	r := Result{Fixes: make([]int, len(zones))}
+	for i := range r.Fixes {
+		r.Fixes[i] = -1
+	}
+	for i := 1; i < len(zones); i++ {
+		p1, p2 := zones[i-1].center, zones[i].center
+		r.Distance += spatial.Distance(p1.Latitude, p1.Longitude, p2.Latitude, p2.Longitude) / 1000
+	}
+
+	next := 0
+	for i := 1; i < len(points) && next < len(zones); i++ {
+		p1, p2 := points[i-1], points[i]
+		if next <= 1 && zones[0].started(p1, p2) && (valid == nil || valid(0, p2)) {
+			r.Fixes[0], next = i, 1
+			continue
+		}
+		if next > 0 {
+			z := zones[next]
+			reached := z.reached(p1, p2)
+			if next == len(zones)-1 {
+				if z.Type != Line {
+					// finish zones must be entered
+					reached = reached && !z.inside(p1)
+				}
+				reached = reached && (valid == nil || valid(next, p2))
+			}
+			if reached {
+				r.Fixes[next] = i
+				next++
+			}
+		}
+	}
+
+	switch {
+	case r.Fixes[0] < 0:
+		r.Reason = "no valid start"
+	case next < len(zones)-1:
+		r.Reason = fmt.Sprintf("turnpoint %v not reached", next)
+	case next < len(zones):
+		r.Reason = "no valid finish"
+	default:
+		r.Completed = true
+	}
+	if r.Fixes[0] >= 0 {
+		r.Start = points[r.Fixes[0]].Time
+	}
+	if r.Completed {
+		r.Finish = points[r.Fixes[len(zones)-1]].Time
+		if d := r.Finish.Sub(r.Start).Hours(); d > 0 {
+			r.Speed = r.Distance / d
+		}
+	}
+	return r
+}
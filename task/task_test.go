@@ -0,0 +1,184 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package task
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+var base = time.Date(2015, 6, 20, 12, 0, 0, 0, time.UTC)
+
+// point returns the point at the given distance (km) and bearing from the
+// task start.
+func point(bearing float64, distance float64) flight.Point {
+	p := flight.NewPoint()
+	p.Latitude, p.Longitude = spatial.Destination(45, 5, bearing, distance*1000)
+	return p
+}
+
+// path returns the points flying (at 100 km/h, a point every 100 m) through
+// the given points.
+func path(through ...flight.Point) []flight.Point {
+	t := base
+	result := []flight.Point{through[0]}
+	result[0].Time = t
+	for _, p := range through[1:] {
+		prev := result[len(result)-1]
+		d := spatial.Distance(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		b := spatial.Bearing(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		for s := 100.0; s < d+100; s += 100 {
+			q := flight.NewPoint()
+			q.Latitude, q.Longitude = spatial.Destination(prev.Latitude, prev.Longitude, b, math.Min(s, d))
+			t = t.Add(3600 * time.Millisecond)
+			q.Time = t
+			result = append(result, q)
+		}
+	}
+	return result
+}
+
+// testTask is a 180 km fai triangle, starting and finishing at the same point.
+func testTask() flight.Task {
+	return flight.Task{
+		Start:      point(0, 0),
+		Turnpoints: []flight.Point{point(0, 60), point(60, 60)},
+		Finish:     point(0, 0),
+	}
+}
+
+type verifyTest struct {
+	t      string
+	points []flight.Point
+	opts   Options
+	result Result
+}
+
+var verifyTests = []verifyTest{
+	{
+		t: "completed",
+		points: path(point(180, 3), point(0, 61), point(60, 61),
+			point(0, 0), point(180, 2)),
+		opts:   DefaultOptions,
+		result: Result{Completed: true, Fixes: []int{30, 0, 0, 0}, Distance: 180, Speed: 100},
+	},
+	{
+		t: "restart",
+		points: path(point(180, 3), point(0, 5), point(180, 3), point(0, 61),
+			point(60, 61), point(0, 0), point(180, 2)),
+		opts:   DefaultOptions,
+		result: Result{Completed: true, Fixes: []int{190, 0, 0, 0}, Distance: 180, Speed: 100},
+	},
+	{
+		t:      "no start",
+		points: path(point(0, 3), point(0, 61), point(60, 61), point(0, 0), point(180, 2)),
+		opts:   DefaultOptions,
+		result: Result{Reason: "no valid start", Fixes: []int{-1, -1, -1, -1}, Distance: 180},
+	},
+	{
+		t:      "start line too short",
+		points: path(point(270, 6), point(0, 60), point(60, 61), point(0, 0), point(180, 2)),
+		opts:   DefaultOptions,
+		result: Result{Reason: "no valid start", Fixes: []int{-1, -1, -1, -1}, Distance: 180},
+	},
+	{
+		t: "turnpoint missed",
+		points: path(point(180, 3), point(0, 55), point(60, 61),
+			point(0, 0), point(180, 2)),
+		opts:   DefaultOptions,
+		result: Result{Reason: "turnpoint 1 not reached", Fixes: []int{30, -1, -1, -1}, Distance: 180},
+	},
+	{
+		t:      "landed out",
+		points: path(point(180, 3), point(0, 61), point(60, 61), point(30, 10)),
+		opts:   DefaultOptions,
+		result: Result{Reason: "no valid finish", Fixes: []int{30, 0, 0, -1}, Distance: 180},
+	},
+	{
+		t: "cylinders",
+		points: path(point(0, 0), point(0, 59.6), point(60, 59.6),
+			point(0, 0.9)),
+		opts: Options{Start: Zone{Type: Cylinder, Radius: 3000},
+			Turnpoint: Zone{Type: Cylinder, Radius: 500}, Finish: Zone{Type: Cylinder, Radius: 1000}},
+		result: Result{Completed: true, Fixes: []int{30, 0, 0, 0}, Distance: 180, Speed: 100},
+	},
+	{
+		t: "keyhole cylinder",
+		points: path(point(180, 3), point(0, 59.6), point(60, 59.6),
+			point(0, 0), point(180, 2)),
+		opts: Options{Start: DefaultOptions.Start,
+			Turnpoint: Zone{Type: Keyhole, Radius: 10000, InnerRadius: 500}, Finish: DefaultOptions.Finish},
+		result: Result{Completed: true, Fixes: []int{30, 0, 0, 0}, Distance: 180, Speed: 100},
+	},
+	{
+		t: "keyhole short",
+		points: path(point(180, 3), point(0, 59), point(60, 59.6),
+			point(0, 0), point(180, 2)),
+		opts: Options{Start: DefaultOptions.Start,
+			Turnpoint: Zone{Type: Keyhole, Radius: 10000, InnerRadius: 500}, Finish: DefaultOptions.Finish},
+		result: Result{Reason: "turnpoint 1 not reached", Fixes: []int{30, -1, -1, -1}, Distance: 180},
+	},
+}
+
+func TestVerify(t *testing.T) {
+	for _, test := range verifyTests {
+		f := flight.NewFlight()
+		f.Task = testTask()
+		f.Points = test.points
+		r, err := Verify(f, test.opts)
+		if err != nil {
+			t.Errorf("%v :: unexpected error :: %v", test.t, err)
+			continue
+		}
+		e := test.result
+		if r.Completed != e.Completed || r.Reason != e.Reason || len(r.Fixes) != len(e.Fixes) ||
+			math.Abs(r.Distance-e.Distance) > 0.5 || math.Abs(r.Speed-e.Speed) > 5 {
+			t.Errorf("%v :: expected %+v got %+v", test.t, e, r)
+			continue
+		}
+		for i, fix := range r.Fixes {
+			// only the start fix and the missing fixes are checked
+			if (i == 0 && fix != e.Fixes[0]) || (e.Fixes[i] < 0) != (fix < 0) {
+				t.Errorf("%v :: expected fixes %v got %v", test.t, e.Fixes, r.Fixes)
+			}
+			if i > 0 && fix >= 0 && fix <= r.Fixes[i-1] {
+				t.Errorf("%v :: fixes not in order :: %v", test.t, r.Fixes)
+			}
+		}
+		if r.Fixes[0] >= 0 && !r.Start.Equal(f.Points[r.Fixes[0]].Time) {
+			t.Errorf("%v :: expected start %v got %v", test.t, f.Points[r.Fixes[0]].Time, r.Start)
+		}
+		if r.Completed && !r.Finish.Equal(f.Points[r.Fixes[len(r.Fixes)-1]].Time) {
+			t.Errorf("%v :: wrong finish time %v", test.t, r.Finish)
+		}
+	}
+}
+
+func TestVerifyNoTask(t *testing.T) {
+	f := flight.NewFlight()
+	f.Points = path(point(0, 0), point(0, 10))
+	if _, err := Verify(f, DefaultOptions); err == nil {
+		t.Errorf("expected error with no task")
+	}
+}
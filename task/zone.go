@@ -0,0 +1,278 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package task
+
+import (
+	"math"
+
+	"github.com/rochaporto/ezgliding/flight"
+	"github.com/rochaporto/ezgliding/spatial"
+)
+
+// ZoneType is the type of an observation zone.
+type ZoneType int
+
+const (
+	// Line is a line perpendicular to the leg, centered on the task point.
+	Line ZoneType = iota
+	// Cylinder is a circle centered on the task point.
+	Cylinder
+	// FAISector is a 90 degree sector, symmetrical to the bisector of the
+	// inbound and outbound legs and oriented away from the course.
+	FAISector
+	// Keyhole is a FAI sector together with a small cylinder.
+	Keyhole
+	// Sector is the area between two radials, going clockwise from
+	// Radial1 to Radial2, and between InnerRadius and Radius.
+	Sector
+	// Polygon is the area inside the Polygon points.
+	Polygon
+)
+
+func (t ZoneType) String() string {
+	switch t {
+	case Line:
+		return "line"
+	case Cylinder:
+		return "cylinder"
+	case FAISector:
+		return "fai sector"
+	case Keyhole:
+		return "keyhole"
+	case Sector:
+		return "sector"
+	case Polygon:
+		return "polygon"
+	default:
+		return "unknown"
+	}
+}
+
+// Zone is an observation zone around a task point.
+type Zone struct {
+	Type ZoneType
+	// Radius (m) is the cylinder and sector radius, 0 meaning unlimited
+	// sectors. For lines it is half the line length.
+	Radius float64
+	// InnerRadius (m) is the cylinder radius of keyholes, and the inner
+	// radius of sectors.
+	InnerRadius float64
+	// Radial1 and Radial2 (deg) are the limits of sectors.
+	Radial1 float64
+	Radial2 float64
+	// Polygon holds the vertices of polygons.
+	Polygon []flight.Point
+}
+
+// faiSectorAngle is the full angle (deg) of FAI sectors.
+const faiSectorAngle = 90.0
+
+// zone is a Zone placed at a task point, with the direction (deg) it points
+// to, the outbound leg for lines and away from the course for sectors.
+type zone struct {
+	Zone
+	center    flight.Point
+	direction float64
+	// polygon holds the coordinates of the Polygon points
+	polygon []spatial.Coordinate
+}
+
+// newZone places z at the given task point, where in and out are the
+// previous and next task points (nil for the start and finish).
+func newZone(z Zone, center flight.Point, in *flight.Point, out *flight.Point) zone {
+	result := zone{Zone: z, center: center}
+	for _, p := range z.Polygon {
+		result.polygon = append(result.polygon, spatial.Coordinate{Latitude: p.Latitude, Longitude: p.Longitude})
+	}
+	var inBearing, outBearing float64
+	if in != nil {
+		inBearing = spatial.Bearing(in.Latitude, in.Longitude, center.Latitude, center.Longitude)
+	}
+	if out != nil {
+		outBearing = spatial.Bearing(center.Latitude, center.Longitude, out.Latitude, out.Longitude)
+	}
+	switch {
+	case in == nil && out == nil:
+	case in == nil:
+		// start, lines are crossed along and sectors point back
+		result.direction = outBearing
+		if z.Type != Line {
+			result.direction = math.Mod(outBearing+180, 360)
+		}
+	case out == nil:
+		// finish, lines are crossed along and sectors point ahead
+		result.direction = inBearing
+	default:
+		// bisector of the reversed outbound leg and the inbound leg
+		x := math.Sin(spatial.Radians(inBearing)) - math.Sin(spatial.Radians(outBearing))
+		y := math.Cos(spatial.Radians(inBearing)) - math.Cos(spatial.Radians(outBearing))
+		if math.Hypot(x, y) < 1e-9 {
+			// out and return, the sector points ahead
+			result.direction = inBearing
+		} else {
+			result.direction = math.Mod(spatial.Degrees(math.Atan2(x, y))+360, 360)
+		}
+	}
+	return result
+}
+
+// position returns the distance (m) of p to the zone center along the zone
+// direction and across it.
+func (z zone) position(p flight.Point) (float64, float64) {
+	d := spatial.Distance(z.center.Latitude, z.center.Longitude, p.Latitude, p.Longitude)
+	if d == 0 {
+		return 0, 0
+	}
+	b := spatial.Bearing(z.center.Latitude, z.center.Longitude, p.Latitude, p.Longitude)
+	angle := spatial.Radians(b - z.direction)
+	return d * math.Cos(angle), d * math.Sin(angle)
+}
+
+// inside checks if p is inside the zone (lines have no inside).
+func (z zone) inside(p flight.Point) bool {
+	d := spatial.Distance(z.center.Latitude, z.center.Longitude, p.Latitude, p.Longitude)
+	switch z.Type {
+	case Cylinder:
+		return d <= z.Radius
+	case Keyhole:
+		if d <= z.InnerRadius {
+			return true
+		}
+		fallthrough
+	case FAISector:
+		if z.Radius > 0 && d > z.Radius {
+			return false
+		}
+		if d == 0 {
+			return true
+		}
+		b := spatial.Bearing(z.center.Latitude, z.center.Longitude, p.Latitude, p.Longitude)
+		return math.Abs(math.Remainder(b-z.direction, 360)) <= faiSectorAngle/2
+	case Sector:
+		if d < z.InnerRadius || d > z.Radius {
+			return false
+		}
+		b := spatial.Bearing(z.center.Latitude, z.center.Longitude, p.Latitude, p.Longitude)
+		return math.Mod(b-z.Radial1+360, 360) <= math.Mod(z.Radial2-z.Radial1+360, 360)
+	case Polygon:
+		return spatial.InPolygon(p.Latitude, p.Longitude, z.polygon)
+	}
+	return false
+}
+
+// boundarySteps is the number of points sampled in each arc or edge of a
+// zone boundary.
+const boundarySteps = 45
+
+// boundary returns points sampled along the zone boundary (or the center for
+// lines and unlimited sectors), where the longest and shortest paths through
+// the zone touch it.
+func (z zone) boundary() []flight.Point {
+	var result []flight.Point
+	arc := func(radius, from, to float64) {
+		if radius <= 0 {
+			result = append(result, z.center)
+			return
+		}
+		for i := 0; i <= boundarySteps; i++ {
+			result = append(result, z.at(from+(to-from)*float64(i)/boundarySteps, radius))
+		}
+	}
+	radial := func(bearing, from, to float64) {
+		for i := 0; i <= boundarySteps; i++ {
+			result = append(result, z.at(bearing, from+(to-from)*float64(i)/boundarySteps))
+		}
+	}
+	switch z.Type {
+	case Cylinder:
+		arc(z.Radius, 0, 360)
+	case Keyhole:
+		arc(z.InnerRadius, 0, 360)
+		fallthrough
+	case FAISector:
+		if z.Radius <= 0 {
+			result = append(result, z.center)
+			break
+		}
+		from, to := z.direction-faiSectorAngle/2, z.direction+faiSectorAngle/2
+		arc(z.Radius, from, to)
+		radial(from, 0, z.Radius)
+		radial(to, 0, z.Radius)
+	case Sector:
+		to := z.Radial1 + math.Mod(z.Radial2-z.Radial1+360, 360)
+		arc(z.Radius, z.Radial1, to)
+		arc(z.InnerRadius, z.Radial1, to)
+		radial(z.Radial1, z.InnerRadius, z.Radius)
+		radial(z.Radial2, z.InnerRadius, z.Radius)
+	case Polygon:
+		for i, a := range z.Polygon {
+			b := z.Polygon[(i+1)%len(z.Polygon)]
+			for k := 0; k < boundarySteps; k++ {
+				f := float64(k) / boundarySteps
+				p := flight.NewPoint()
+				p.Latitude = a.Latitude + (b.Latitude-a.Latitude)*f
+				p.Longitude = a.Longitude + (b.Longitude-a.Longitude)*f
+				result = append(result, p)
+			}
+		}
+	default:
+		result = append(result, z.center)
+	}
+	return result
+}
+
+// at returns the point at the given bearing (deg) and distance (m) from the
+// zone center.
+func (z zone) at(bearing, distance float64) flight.Point {
+	p := flight.NewPoint()
+	p.Latitude, p.Longitude = spatial.Destination(z.center.Latitude, z.center.Longitude, bearing, distance)
+	return p
+}
+
+// crossed checks if the line was crossed in its direction going from p1 to
+// p2, within its length.
+func (z zone) crossed(p1, p2 flight.Point) bool {
+	a1, c1 := z.position(p1)
+	a2, c2 := z.position(p2)
+	if a1 >= 0 || a2 < 0 {
+		return false
+	}
+	// across distance where the segment meets the line
+	c := c1 + (c2-c1)*(-a1)/(a2-a1)
+	return math.Abs(c) <= z.Radius
+}
+
+// started checks if going from p1 to p2 is a valid start: crossing a line
+// or leaving any other zone.
+func (z zone) started(p1, p2 flight.Point) bool {
+	if z.Type == Line {
+		return z.crossed(p1, p2)
+	}
+	return z.inside(p1) && !z.inside(p2)
+}
+
+// reached checks if going from p1 to p2 reaches the zone: crossing a line or
+// being inside any other zone.
+func (z zone) reached(p1, p2 flight.Point) bool {
+	if z.Type == Line {
+		return z.crossed(p1, p2)
+	}
+	return z.inside(p2)
+}
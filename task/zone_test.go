@@ -0,0 +1,104 @@
+// Copyright 2015 The ezgliding Authors.
+//
+// This file is part of ezgliding.
+//
+// ezgliding is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ezgliding is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ezgliding.  If not, see <http://www.gnu.org/licenses/>.
+//
+// Author: Ricardo Rocha <rocha.porto@gmail.com>
+
+package task
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rochaporto/ezgliding/flight"
+)
+
+func TestZoneDirection(t *testing.T) {
+	tests := []struct {
+		t         string
+		z         Zone
+		in, out   *flight.Point
+		direction float64
+	}{
+		{"start line", Zone{Type: Line}, nil, &flight.Point{Latitude: 46, Longitude: 5}, 0},
+		{"start sector", Zone{Type: FAISector}, nil, &flight.Point{Latitude: 46, Longitude: 5}, 180},
+		{"finish", Zone{Type: Line}, &flight.Point{Latitude: 44, Longitude: 5}, nil, 0},
+		{"turn right", Zone{Type: FAISector}, &flight.Point{Latitude: 44, Longitude: 5},
+			&flight.Point{Latitude: 45, Longitude: 6}, 315},
+		{"out and return", Zone{Type: FAISector}, &flight.Point{Latitude: 44, Longitude: 5},
+			&flight.Point{Latitude: 44, Longitude: 5}, 0},
+	}
+	for _, test := range tests {
+		z := newZone(test.z, flight.Point{Latitude: 45, Longitude: 5}, test.in, test.out)
+		if math.Abs(math.Remainder(z.direction-test.direction, 360)) > 1 {
+			t.Errorf("%v :: expected direction %v got %v", test.t, test.direction, z.direction)
+		}
+	}
+}
+
+func TestZoneInside(t *testing.T) {
+	center := point(0, 0)
+	sector := newZone(Zone{Type: FAISector, Radius: 10000}, center, nil, nil)
+	keyhole := newZone(Zone{Type: Keyhole, Radius: 10000, InnerRadius: 500}, center, nil, nil)
+	cylinder := newZone(Zone{Type: Cylinder, Radius: 500}, center, nil, nil)
+	line := newZone(Zone{Type: Line, Radius: 500}, center, nil, nil)
+	tests := []struct {
+		p                                   flight.Point
+		sector, keyhole, cylinder, isInside bool
+	}{
+		{point(0, 0), true, true, true, false},
+		{point(0, 5), true, true, false, false},
+		{point(40, 5), true, true, false, false},
+		{point(50, 5), false, false, false, false},
+		{point(180, 0.4), false, true, true, false},
+		{point(0, 11), false, false, false, false},
+	}
+	for i, test := range tests {
+		if sector.inside(test.p) != test.sector || keyhole.inside(test.p) != test.keyhole ||
+			cylinder.inside(test.p) != test.cylinder || line.inside(test.p) != test.isInside {
+			t.Errorf("%v :: unexpected inside result for %+v", i, test)
+		}
+	}
+}
+
+func TestZoneCrossed(t *testing.T) {
+	line := newZone(Zone{Type: Line, Radius: 500}, point(0, 0), nil, nil)
+	tests := []struct {
+		p1, p2  flight.Point
+		crossed bool
+	}{
+		{point(180, 0.1), point(0, 0.1), true},
+		{point(0, 0.1), point(180, 0.1), false},
+		{point(200, 0.5), point(20, 0.5), true},
+		{point(240, 1.2), point(300, 1.2), false},
+		{point(0, 0.1), point(0, 0.2), false},
+	}
+	for i, test := range tests {
+		if line.crossed(test.p1, test.p2) != test.crossed {
+			t.Errorf("%v :: expected crossed %v", i, test.crossed)
+		}
+	}
+}
+
+func TestZoneTypeString(t *testing.T) {
+	types := map[ZoneType]string{Line: "line", Cylinder: "cylinder", FAISector: "fai sector",
+		Keyhole: "keyhole", ZoneType(10): "unknown"}
+	for k, v := range types {
+		if k.String() != v {
+			t.Errorf("expected %v got %v", v, k.String())
+		}
+	}
+}